@@ -58,6 +58,7 @@ func main() {
 	fmt.Println("SOURCE:")
 	fmt.Println(res.Source)
 	fmt.Println("\nFINAL:", res.Final)
+	fmt.Println("SUMMARY:", res.Summary())
 
 	fmt.Println("\nCHUNKS:")
 	for _, c := range res.Chunks {
@@ -65,7 +66,8 @@ func main() {
 			c.ID, c.Fingerprint, c.Expr, c.Value, c.Skipped, c.Reason, c.Error)
 	}
 
-	fmt.Println("\n====================================================================================\n")
+	fmt.Println("\n====================================================================================")
+	fmt.Println()
 
 	// if your tracer enabled Cond registration (EnableCond: true or equivalent) so expr can compile Cond(...) as a function.
 	input2 := `len(tweets)+len(tweets)`
@@ -74,6 +76,7 @@ func main() {
 	fmt.Println("SOURCE:")
 	fmt.Println(res2.Source)
 	fmt.Println("\nFINAL:", res2.Final)
+	fmt.Println("SUMMARY:", res2.Summary())
 
 	fmt.Println("\nCHUNKS:")
 	for _, c := range res2.Chunks {
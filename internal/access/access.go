@@ -0,0 +1,270 @@
+package access
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser/utils"
+	"github.com/expr-lang/expr/vm/runtime"
+)
+
+// FuncName is the hidden function env reads are rewritten to. The `$` prefix keeps it
+// out of the way of user functions; it never appears in formatted sources.
+const FuncName = "$access"
+
+// Recorder captures the env paths an expression dereferences and the values it read.
+//
+// Its Options rewrite every member chain rooted at an env identifier with static
+// properties, e.g. `user?.Profile.Age` or `tweets[0]`, into a single call
+//
+//	$access("user", "Profile", true, "Age", false)
+//
+// (name, then property/optional pairs) at compile time, so the recorded path is the
+// longest one read rather than each of its prefixes. Chains that continue with a
+// dynamic property (`user[key]`) or a method call record their static prefix.
+type Recorder struct {
+	env     map[string]interface{}
+	seen    map[string]interface{}
+	resolve Resolver
+	skip    func(name string) bool
+}
+
+// Resolver supplies the value at a path whose name is not in the env.
+type Resolver func(path string) (interface{}, error)
+
+func NewRecorder(env map[string]interface{}) *Recorder {
+	return &Recorder{env: env, seen: map[string]interface{}{}}
+}
+
+// NewResolvingRecorder is a Recorder that also rewrites identifiers missing from env,
+// except those for which skip returns true (functions, let bindings), and reads them
+// through resolve. resolve receives the longest static path of the chain, e.g.
+// `comment.UserId`, and is called each time the chain is evaluated.
+func NewResolvingRecorder(env map[string]interface{}, resolve Resolver, skip func(name string) bool) *Recorder {
+	return &Recorder{env: env, seen: map[string]interface{}{}, resolve: resolve, skip: skip}
+}
+
+// Seen returns the recorded values keyed by path (see Path).
+func (r *Recorder) Seen() map[string]interface{} { return r.seen }
+
+// Options returns the compile options that route env reads through the recorder.
+func (r *Recorder) Options() []expr.Option {
+	return []expr.Option{
+		expr.Function(FuncName, r.Func()),
+		expr.Patch(patcher{env: r.env, resolvable: r.resolvable}),
+	}
+}
+
+// Func returns the implementation of FuncName. A nil value before an optional
+// property ends the chain with nil, as `?.` does; the value read up to that point
+// (or up to a failing fetch) is what gets recorded.
+func (r *Recorder) Func() func(params ...any) (any, error) {
+	return func(params ...any) (any, error) {
+		if len(params)%2 != 1 {
+			return nil, fmt.Errorf("%s expects a name followed by property/optional pairs", FuncName)
+		}
+		name, _ := params[0].(string)
+		path := name
+		v, ok := r.env[name]
+		if !ok && r.resolve != nil {
+			for i := 1; i < len(params); i += 2 {
+				path += segment(params[i])
+			}
+			v, err := r.resolve(path)
+			if err != nil {
+				return nil, err
+			}
+			r.seen[path] = v
+			return v, nil
+		}
+		for i := 1; i < len(params); i += 2 {
+			if optional, _ := params[i+1].(bool); optional && isNil(v) {
+				r.seen[path] = nil
+				return nil, nil
+			}
+			next, err := fetch(v, params[i])
+			if err != nil {
+				r.seen[path] = v
+				return nil, err
+			}
+			v = next
+			path += segment(params[i])
+		}
+		r.seen[path] = v
+		return v, nil
+	}
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func fetch(from, prop any) (v any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	return runtime.Fetch(from, prop), nil
+}
+
+// patcher rewrites env member chains into FuncName calls. ast.Walk visits children
+// first, so an identifier becomes a call before its member node is visited and each
+// static member folds into the call below it.
+type patcher struct {
+	env        map[string]interface{}
+	resolvable func(name string) bool
+}
+
+func (p patcher) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		v, ok := p.env[n.Value]
+		if !ok && !p.resolvable(n.Value) || (v != nil && reflect.TypeOf(v).Kind() == reflect.Func) {
+			return
+		}
+		ast.Patch(node, &ast.CallNode{
+			Callee:    &ast.IdentifierNode{Value: FuncName},
+			Arguments: []ast.Node{&ast.StringNode{Value: n.Value}},
+		})
+
+	case *ast.MemberNode:
+		call, ok := n.Node.(*ast.CallNode)
+		if !ok || !isAccess(call) || n.Method {
+			return
+		}
+		var prop ast.Node
+		switch x := n.Property.(type) {
+		case *ast.StringNode:
+			prop = &ast.StringNode{Value: x.Value}
+		case *ast.IntegerNode:
+			prop = &ast.IntegerNode{Value: x.Value}
+		default:
+			// A dynamic property stays a member access on the recorded prefix; keep
+			// the nil-propagation of an optional segment folded into it.
+			if hasOptional(call) {
+				n.Optional = true
+			}
+			return
+		}
+		call.Arguments = append(call.Arguments, prop, &ast.BoolNode{Value: n.Optional})
+		ast.Patch(node, call)
+	}
+}
+
+// resolvable reports whether name, missing from the env, is read through the resolver.
+func (r *Recorder) resolvable(name string) bool {
+	return r.resolve != nil && !strings.HasPrefix(name, "$") && !r.skip(name)
+}
+
+func isAccess(call *ast.CallNode) bool {
+	id, ok := call.Callee.(*ast.IdentifierNode)
+	return ok && id.Value == FuncName
+}
+
+func hasOptional(call *ast.CallNode) bool {
+	for i := 2; i < len(call.Arguments); i += 2 {
+		if b, ok := call.Arguments[i].(*ast.BoolNode); ok && b.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// segment renders one property of a path: `.Name` for identifier-like strings,
+// `[0]` for indexes and `["some key"]` otherwise.
+func segment(prop any) string {
+	switch p := prop.(type) {
+	case string:
+		if utils.IsValidIdentifier(p) {
+			return "." + p
+		}
+		return "[" + strconv.Quote(p) + "]"
+	default:
+		return fmt.Sprintf("[%v]", p)
+	}
+}
+
+// Path renders a name and its properties the way Recorder keys them.
+func Path(name string, props ...any) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, p := range props {
+		sb.WriteString(segment(p))
+	}
+	return sb.String()
+}
+
+// Split parses a path produced by Path back into its name and properties
+// (strings for keys and fields, ints for indexes).
+func Split(path string) (name string, props []any, err error) {
+	i := strings.IndexAny(path, ".[")
+	if i < 0 {
+		return path, nil, nil
+	}
+	name, rest := path[:i], path[i:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			j := strings.IndexAny(rest[1:], ".[")
+			if j < 0 {
+				j = len(rest) - 1
+			}
+			if j == 0 {
+				return "", nil, fmt.Errorf("access path %q: empty property", path)
+			}
+			props = append(props, rest[1:j+1])
+			rest = rest[j+1:]
+		case '[':
+			j := closing(rest)
+			if j < 0 {
+				return "", nil, fmt.Errorf("access path %q: unterminated [", path)
+			}
+			inner := rest[1:j]
+			if strings.HasPrefix(inner, `"`) {
+				s, err := strconv.Unquote(inner)
+				if err != nil {
+					return "", nil, fmt.Errorf("access path %q: %w", path, err)
+				}
+				props = append(props, s)
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return "", nil, fmt.Errorf("access path %q: %w", path, err)
+				}
+				props = append(props, n)
+			}
+			rest = rest[j+1:]
+		default:
+			return "", nil, fmt.Errorf("access path %q: unexpected %q", path, rest[0])
+		}
+	}
+	return name, props, nil
+}
+
+// closing returns the index of the `]` matching s[0] == '[', skipping quoted keys.
+func closing(s string) int {
+	inStr := false
+	for i := 1; i < len(s); i++ {
+		switch {
+		case inStr && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			inStr = !inStr
+		case !inStr && s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,135 @@
+package access
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPathSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		props []any
+		want  string
+	}{
+		{"user", nil, "user"},
+		{"user", []any{"Group"}, "user.Group"},
+		{"tweets", []any{0, "Len"}, "tweets[0].Len"},
+		{"comment", []any{"odd key"}, `comment["odd key"]`},
+		{"m", []any{"a.b", "c"}, `m["a.b"].c`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := Path(tt.name, tt.props...); got != tt.want {
+				t.Fatalf("Path = %q, want %q", got, tt.want)
+			}
+			name, props, err := Split(tt.want)
+			if err != nil {
+				t.Fatalf("Split: %v", err)
+			}
+			if name != tt.name || len(props) != len(tt.props) || (len(props) > 0 && !reflect.DeepEqual(props, tt.props)) {
+				t.Errorf("Split = %q %v, want %q %v", name, props, tt.name, tt.props)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	got, err := Expand(map[string]interface{}{
+		"user.Group":         "admin",
+		"user.Profile":       map[string]interface{}{"Age": 3},
+		"user.Profile.Age":   3, // covered by user.Profile
+		"tweets[1].Len":      120,
+		`comment["odd key"]`: true,
+	})
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	want := map[string]interface{}{
+		"user":    map[string]interface{}{"Group": "admin", "Profile": map[string]interface{}{"Age": 3}},
+		"tweets":  []interface{}{nil, map[string]interface{}{"Len": 120}},
+		"comment": map[string]interface{}{"odd key": true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand = %#v\nwant %#v", got, want)
+	}
+}
+
+type account struct {
+	Name  string
+	Age   int64
+	Roles []string
+}
+
+func TestWith(t *testing.T) {
+	env := func() map[string]interface{} {
+		return map[string]interface{}{
+			"user":    map[string]interface{}{"Group": "admin", "Profile": map[string]interface{}{"Age": 30}},
+			"tweets":  []interface{}{map[string]interface{}{"Len": 10}},
+			"acct":    &account{Name: "bob", Age: 30, Roles: []string{"a", "b"}},
+			"pair":    [2]int{1, 2},
+			"counter": 1,
+		}
+	}
+	tests := []struct {
+		path string
+		v    interface{}
+		read func(map[string]interface{}) interface{}
+		want interface{}
+	}{
+		{"counter", 2, func(e map[string]interface{}) interface{} { return e["counter"] }, 2},
+		{"user.Group", "guest", func(e map[string]interface{}) interface{} {
+			return e["user"].(map[string]interface{})["Group"]
+		}, "guest"},
+		{"user.Profile.Age", 17, func(e map[string]interface{}) interface{} {
+			return e["user"].(map[string]interface{})["Profile"].(map[string]interface{})["Age"]
+		}, 17},
+		{"user.New.Deep", true, func(e map[string]interface{}) interface{} {
+			return e["user"].(map[string]interface{})["New"].(map[string]interface{})["Deep"]
+		}, true},
+		{"tweets[2].Len", 5, func(e map[string]interface{}) interface{} {
+			return e["tweets"].([]interface{})[2].(map[string]interface{})["Len"]
+		}, 5},
+		{"acct.Age", 17, func(e map[string]interface{}) interface{} { return e["acct"].(*account).Age }, int64(17)},
+		{"acct.Roles[1]", "z", func(e map[string]interface{}) interface{} { return e["acct"].(*account).Roles[1] }, "z"},
+		{"pair[0]", 9, func(e map[string]interface{}) interface{} { return e["pair"].([2]int)[0] }, 9},
+		{"missing.Key", nil, func(e map[string]interface{}) interface{} {
+			return e["missing"].(map[string]interface{})["Key"]
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			orig := env()
+			got, err := With(orig, tt.path, tt.v)
+			if err != nil {
+				t.Fatalf("With: %v", err)
+			}
+			if v := tt.read(got); !reflect.DeepEqual(v, tt.want) {
+				t.Errorf("value at %s = %#v, want %#v", tt.path, v, tt.want)
+			}
+			if !reflect.DeepEqual(orig, env()) {
+				t.Errorf("env was modified: %#v", orig)
+			}
+		})
+	}
+}
+
+func TestWithErrors(t *testing.T) {
+	env := map[string]interface{}{
+		"acct":  &account{},
+		"pair":  [2]int{},
+		"name":  "bob",
+		"nilp":  (*account)(nil),
+		"slice": []int{1},
+	}
+	for _, path := range []string{"acct.Missing", "acct.Age", "pair[2]", "name.First", "nilp.Name", "slice.x", "slice[0]", "a..b"} {
+		t.Run(path, func(t *testing.T) {
+			v := interface{}("text")
+			if path == "slice[0]" || path == "acct.Missing" {
+				v = "x" // string into []int, and a field that does not exist
+			}
+			if _, err := With(env, path, v); err == nil {
+				t.Fatalf("With(%s): want an error", path)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package access
+
+import (
+	"sort"
+)
+
+// Expand rebuilds a nested env from recorded paths, so an expression reading only
+// those paths evaluates the same against it. Containers along a path become
+// map[string]interface{} (or []interface{} for indexes); a path whose prefix was
+// recorded as a whole value is already covered by it and is skipped.
+func Expand(paths map[string]interface{}) (map[string]interface{}, error) {
+	type entry struct {
+		path  string
+		name  string
+		props []any
+	}
+	entries := make([]entry, 0, len(paths))
+	for p := range paths {
+		name, props, err := Split(p)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{p, name, props})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if len(entries[i].props) != len(entries[j].props) {
+			return len(entries[i].props) < len(entries[j].props)
+		}
+		return entries[i].path < entries[j].path
+	})
+
+	env := map[string]interface{}{}
+	for _, e := range entries {
+		if coveredByPrefix(paths, e.name, e.props) {
+			continue
+		}
+		if len(e.props) == 0 {
+			env[e.name] = paths[e.path]
+			continue
+		}
+		env[e.name] = set(env[e.name], e.props, paths[e.path])
+	}
+	return env, nil
+}
+
+func coveredByPrefix(paths map[string]interface{}, name string, props []any) bool {
+	for i := 0; i < len(props); i++ {
+		if _, ok := paths[Path(name, props[:i]...)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// set stores v at props under container, creating containers as needed, and
+// returns the (possibly grown) container.
+func set(container any, props []any, v any) any {
+	switch p := props[0].(type) {
+	case int:
+		list, _ := container.([]interface{})
+		for len(list) <= p {
+			list = append(list, nil)
+		}
+		if len(props) == 1 {
+			list[p] = v
+		} else {
+			list[p] = set(list[p], props[1:], v)
+		}
+		return list
+	case string:
+		m, ok := container.(map[string]interface{})
+		if !ok {
+			m = map[string]interface{}{}
+		}
+		if len(props) == 1 {
+			m[p] = v
+		} else {
+			m[p] = set(m[p], props[1:], v)
+		}
+		return m
+	}
+	return container
+}
@@ -0,0 +1,76 @@
+package access
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/expr-lang/expr/ast"
+)
+
+// Reads returns the env paths n reads, found statically: a member chain with static
+// properties from an identifier is one path (user.Group, tweets[0].Len), and a dynamic
+// property ends the chain at its base. Names bound by let, closure arguments (#) and
+// names for which skip returns true are left out. Paths are deduplicated and sorted.
+func Reads(n ast.Node, skip func(name string) bool) []string {
+	r := &reads{paths: map[ast.Node]string{}, used: map[ast.Node]bool{}, bound: map[string]bool{}}
+	ast.Walk(&n, r)
+	set := map[string]bool{}
+	for node, p := range r.paths {
+		name := p
+		if i := strings.IndexAny(p, ".["); i >= 0 {
+			name = p[:i]
+		}
+		if !r.used[node] && !r.bound[name] && (skip == nil || !skip(name)) {
+			set[p] = true
+		}
+	}
+	out := make([]string, 0, len(set))
+	for p := range set {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// reads collects a path for every identifier and static member chain. ast.Walk visits
+// children first, so a member node extends the path of its base and marks the base used.
+type reads struct {
+	paths map[ast.Node]string
+	used  map[ast.Node]bool
+	bound map[string]bool
+}
+
+func (r *reads) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		r.paths[n] = n.Value
+	case *ast.VariableDeclaratorNode:
+		r.bound[n.Name] = true
+	case *ast.MemberNode:
+		base, ok := r.paths[n.Node]
+		if !ok || n.Method {
+			return
+		}
+		switch p := n.Property.(type) {
+		case *ast.StringNode:
+			r.paths[n] = Path(base, p.Value)
+		case *ast.IntegerNode:
+			r.paths[n] = Path(base, p.Value)
+		default:
+			return
+		}
+		r.used[n.Node] = true
+	}
+}
+
+// Overlaps reports whether paths a and b read or write a common value: they are equal or
+// one is a prefix of the other (user and user.Group, tweets[0] and tweets[0].Len).
+func Overlaps(a, b string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if !strings.HasPrefix(b, a) {
+		return false
+	}
+	return len(a) == len(b) || b[len(a)] == '.' || b[len(a)] == '['
+}
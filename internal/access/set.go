@@ -0,0 +1,171 @@
+package access
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// With returns a copy of env with v stored at path. Only the containers along the path
+// are copied (maps, slices, structs and pointers to structs); everything else is shared
+// with env, which is never modified.
+func With(env map[string]interface{}, path string, v interface{}) (map[string]interface{}, error) {
+	name, props, err := Split(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, len(env)+1)
+	for k, x := range env {
+		out[k] = x
+	}
+	if len(props) == 0 {
+		out[name] = v
+		return out, nil
+	}
+
+	var cur reflect.Value
+	if x, ok := env[name]; ok && x != nil {
+		cur = reflect.ValueOf(x)
+	}
+	nv, err := setIn(cur, props, reflect.ValueOf(v), path)
+	if err != nil {
+		return nil, err
+	}
+	out[name] = nv.Interface()
+	return out, nil
+}
+
+// setIn returns a copy of cur with v stored at props. An invalid cur (missing or nil)
+// becomes a map[string]interface{} or []interface{} depending on the property.
+func setIn(cur reflect.Value, props []any, v reflect.Value, path string) (reflect.Value, error) {
+	for cur.IsValid() && cur.Kind() == reflect.Interface {
+		if cur.IsNil() {
+			cur = reflect.Value{}
+			break
+		}
+		cur = cur.Elem()
+	}
+	if !cur.IsValid() {
+		if _, ok := props[0].(int); ok {
+			cur = reflect.ValueOf([]interface{}{})
+		} else {
+			cur = reflect.ValueOf(map[string]interface{}{})
+		}
+	}
+
+	child := func(c reflect.Value) (reflect.Value, error) {
+		if len(props) == 1 {
+			return v, nil
+		}
+		return setIn(c, props[1:], v, path)
+	}
+
+	switch cur.Kind() {
+	case reflect.Map:
+		key, err := assignable(reflect.ValueOf(props[0]), cur.Type().Key(), path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		m := reflect.MakeMapWithSize(cur.Type(), cur.Len()+1)
+		if !cur.IsNil() {
+			iter := cur.MapRange()
+			for iter.Next() {
+				m.SetMapIndex(iter.Key(), iter.Value())
+			}
+		}
+		var old reflect.Value
+		if !cur.IsNil() {
+			old = cur.MapIndex(key)
+		}
+		nv, err := child(old)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if nv, err = assignable(nv, cur.Type().Elem(), path); err != nil {
+			return reflect.Value{}, err
+		}
+		m.SetMapIndex(key, nv)
+		return m, nil
+
+	case reflect.Slice, reflect.Array:
+		i, ok := props[0].(int)
+		if !ok || i < 0 {
+			return reflect.Value{}, fmt.Errorf("override %s: %v is not an index", path, props[0])
+		}
+		if cur.Kind() == reflect.Array && i >= cur.Len() {
+			return reflect.Value{}, fmt.Errorf("override %s: index %d out of range", path, i)
+		}
+		var s reflect.Value
+		if cur.Kind() == reflect.Array {
+			s = reflect.New(cur.Type()).Elem()
+			reflect.Copy(s, cur)
+		} else {
+			n := cur.Len()
+			if i >= n {
+				n = i + 1
+			}
+			s = reflect.MakeSlice(cur.Type(), n, n)
+			reflect.Copy(s, cur)
+		}
+		nv, err := child(s.Index(i))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if nv, err = assignable(nv, cur.Type().Elem(), path); err != nil {
+			return reflect.Value{}, err
+		}
+		s.Index(i).Set(nv)
+		return s, nil
+
+	case reflect.Ptr:
+		if cur.IsNil() || cur.Elem().Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("override %s: cannot set %v on %s", path, props[0], cur.Type())
+		}
+		nv, err := setIn(cur.Elem(), props, v, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(cur.Type().Elem())
+		p.Elem().Set(nv)
+		return p, nil
+
+	case reflect.Struct:
+		name, ok := props[0].(string)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("override %s: %v is not a field of %s", path, props[0], cur.Type())
+		}
+		s := reflect.New(cur.Type()).Elem()
+		s.Set(cur)
+		f := s.FieldByName(name)
+		if !f.IsValid() || !f.CanSet() {
+			return reflect.Value{}, fmt.Errorf("override %s: %s has no exported field %s", path, cur.Type(), name)
+		}
+		nv, err := child(f)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if nv, err = assignable(nv, f.Type(), path); err != nil {
+			return reflect.Value{}, err
+		}
+		f.Set(nv)
+		return s, nil
+	}
+	return reflect.Value{}, fmt.Errorf("override %s: cannot set %v on %s", path, props[0], cur.Type())
+}
+
+// assignable converts v to t where expr would treat them alike (e.g. int to int64);
+// an invalid v (nil) becomes t's zero value.
+func assignable(v reflect.Value, t reflect.Type, path string) (reflect.Value, error) {
+	switch {
+	case !v.IsValid():
+		return reflect.Zero(t), nil
+	case v.Type().AssignableTo(t):
+		return v, nil
+	case v.Type().ConvertibleTo(t) && isNumber(v.Kind()) == isNumber(t.Kind()):
+		return v.Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("override %s: cannot use %s as %s", path, v.Type(), t)
+}
+
+func isNumber(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
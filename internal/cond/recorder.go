@@ -1,25 +1,70 @@
 package cond
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr/vm/runtime"
+)
+
+// UnknownPolicy decides what a Cond3 predicate that errored or evaluated to nil
+// returns to the surrounding expression.
+type UnknownPolicy uint8
+
+const (
+	UnknownAsFalse UnknownPolicy = iota
+	UnknownAsTrue
+	UnknownPropagate
+)
+
+func (p UnknownPolicy) String() string {
+	return [...]string{"UnknownAsFalse", "UnknownAsTrue", "UnknownPropagate"}[p]
+}
 
 // Recorded is the minimal info we store per semantic condition.
 type Recorded struct {
-	ID     string
-	Value  bool
-	Reason string
+	ID      string
+	Value   bool
+	Unknown bool   // predicate errored or was nil (Cond3 only)
+	Err     string // predicate error behind an unknown outcome, if any
+	Reason  string
 }
 
 // Recorder captures Cond(...) outcomes during evaluation.
 type Recorder struct {
-	seen map[string]Recorded
+	seen   map[string]Recorded
+	log    []Recorded // every recording, in order (see Mark)
+	policy UnknownPolicy
 }
 
-func NewRecorder() *Recorder {
-	return &Recorder{seen: map[string]Recorded{}}
+func NewRecorder(policy UnknownPolicy) *Recorder {
+	return &Recorder{seen: map[string]Recorded{}, policy: policy}
 }
 
 func (r *Recorder) Seen() map[string]Recorded { return r.seen }
 
+// Mark returns a position in the recording log for Since.
+func (r *Recorder) Mark() int { return len(r.log) }
+
+// Since returns the recordings made after mark.
+func (r *Recorder) Since(mark int) []Recorded {
+	return append([]Recorded(nil), r.log[mark:]...)
+}
+
+// Replay records recs again, as if the calls that made them had just run.
+func (r *Recorder) Replay(recs []Recorded) {
+	for _, rec := range recs {
+		r.record(rec)
+	}
+}
+
+func (r *Recorder) record(rec Recorded) {
+	r.seen[rec.ID] = rec
+	r.log = append(r.log, rec)
+}
+
 // Func returns a function compatible with expr.Function("Cond", ...).
 // Signature: Cond(id, reasonTrue, reasonFalse, predicateBool) bool
 func (r *Recorder) Func() func(params ...any) (any, error) {
@@ -39,7 +84,156 @@ func (r *Recorder) Func() func(params ...any) (any, error) {
 		if pred {
 			reason = rt
 		}
-		r.seen[id] = Recorded{ID: id, Value: pred, Reason: reason}
+		r.record(Recorded{ID: id, Value: pred, Reason: reason})
 		return pred, nil
 	}
 }
+
+// Func3 returns a function compatible with expr.Function("Cond3", ...).
+// It is the three-valued variant of Cond and accepts two forms:
+//
+//	Cond3(id, reasonTrue, reasonFalse, reasonUnknown, predicate)
+//	Cond3(id, reasonTrue, reasonFalse, reasonUnknown, op, left, right)
+//
+// In the first form a nil or non-bool predicate is unknown. The second form is
+// what the AST patcher emits: the comparison itself runs inside Cond3, so a nil
+// operand or a runtime error (e.g. `"a" > 18`) becomes unknown instead of failing
+// the rule or comparing nil.
+// The recorder's UnknownPolicy decides what an unknown outcome returns.
+func (r *Recorder) Func3() func(params ...any) (any, error) {
+	return func(params ...any) (any, error) {
+		if len(params) != 5 && len(params) != 7 {
+			return nil, fmt.Errorf("Cond3 expects 5 args (id, reasonTrue, reasonFalse, reasonUnknown, predicate) " +
+				"or 7 args (id, reasonTrue, reasonFalse, reasonUnknown, op, left, right)")
+		}
+		id, _ := params[0].(string)
+		rt, _ := params[1].(string)
+		rf, _ := params[2].(string)
+		ru, _ := params[3].(string)
+
+		var (
+			pred bool
+			err  error
+		)
+		if len(params) == 5 {
+			pred, err = asBool(params[4])
+		} else {
+			op, _ := params[4].(string)
+			switch {
+			case params[5] == nil:
+				err = fmt.Errorf("left operand of %s is nil", op)
+			case params[6] == nil:
+				err = fmt.Errorf("right operand of %s is nil", op)
+			default:
+				pred, err = Compare(op, params[5], params[6])
+			}
+		}
+
+		if err == nil {
+			reason := rf
+			if pred {
+				reason = rt
+			}
+			r.record(Recorded{ID: id, Value: pred, Reason: reason})
+			return pred, nil
+		}
+
+		rec := Recorded{ID: id, Unknown: true, Err: err.Error(), Reason: ru}
+		switch r.policy {
+		case UnknownAsTrue:
+			rec.Value = true
+		case UnknownPropagate:
+			r.record(rec)
+			return nil, err
+		}
+		r.record(rec)
+		return rec.Value, nil
+	}
+}
+
+func asBool(v any) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case nil:
+		return false, fmt.Errorf("predicate is nil")
+	default:
+		return false, fmt.Errorf("predicate must be bool, got %T", v)
+	}
+}
+
+// Compare applies an atom operator (see patch.IsAtomNode) with expr semantics,
+// turning runtime panics into errors. Comparisons and `in` are expr's runtime helpers;
+// the string operators are what the expr VM runs for them.
+func Compare(op string, left, right any) (res bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+
+	switch op {
+	case "==":
+		return runtime.Equal(left, right), nil
+	case "!=":
+		return !runtime.Equal(left, right), nil
+	case "<":
+		return runtime.Less(left, right), nil
+	case "<=":
+		return runtime.LessOrEqual(left, right), nil
+	case ">":
+		return runtime.More(left, right), nil
+	case ">=":
+		return runtime.MoreOrEqual(left, right), nil
+	case "in":
+		return runtime.In(left, right), nil
+	}
+
+	ls, lok := left.(string)
+	rs, rok := right.(string)
+	if !lok || !rok {
+		return false, fmt.Errorf("invalid operation: %T %s %T", left, op, right)
+	}
+	switch op {
+	case "matches":
+		re, err := pattern(rs)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(ls), nil
+	case "contains":
+		return strings.Contains(ls, rs), nil
+	case "startsWith":
+		return strings.HasPrefix(ls, rs), nil
+	case "endsWith":
+		return strings.HasSuffix(ls, rs), nil
+	default:
+		return false, fmt.Errorf("unsupported Cond3 operator %q", op)
+	}
+}
+
+// patterns caches the regexps compiled for `matches`, as expr does for constant patterns;
+// it is emptied when it grows past maxPatterns.
+var patterns = struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}{m: map[string]*regexp.Regexp{}}
+
+const maxPatterns = 256
+
+func pattern(src string) (*regexp.Regexp, error) {
+	patterns.Lock()
+	defer patterns.Unlock()
+	if re, ok := patterns.m[src]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns.m) >= maxPatterns {
+		patterns.m = map[string]*regexp.Regexp{}
+	}
+	patterns.m[src] = re
+	return re, nil
+}
@@ -0,0 +1,43 @@
+package cond
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		op          string
+		left, right any
+		want        bool
+		wantErr     bool
+	}{
+		{"==", 1, 1.0, true, false},
+		{"!=", "a", nil, true, false},
+		{">=", 18, 18, true, false},
+		{"<", "a", "b", true, false},
+		{"in", "a", []any{"a", "b"}, true, false},
+		{">", "a", 1, false, true},
+		{"contains", "abc", "b", true, false},
+		{"startsWith", "abc", "b", false, false},
+		{"endsWith", "abc", "bc", true, false},
+		{"matches", "aaa", "^a+$", true, false},
+		{"matches", "aab", "^a+$", false, false},
+		{"matches", "a", "(", false, true},
+		{"contains", 1, "a", false, true},
+		{"like", "a", "a", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			got, err := Compare(tt.op, tt.left, tt.right)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("Compare(%q, %v, %v) = %v, %v; want %v, error %v", tt.op, tt.left, tt.right, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	re, err := pattern("^a+$")
+	if err != nil {
+		t.Fatalf("pattern: %v", err)
+	}
+	if again, _ := pattern("^a+$"); again != re {
+		t.Error("pattern compiled twice")
+	}
+}
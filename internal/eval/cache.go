@@ -4,17 +4,36 @@ import (
 	"sync"
 
 	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
 	"github.com/expr-lang/expr/vm"
 )
 
 // Cache caches compiled programs for the lifetime of a single Trace call.
 type Cache struct {
-	mu   sync.Mutex
-	prog map[string]*vm.Program
+	mu    sync.Mutex
+	prog  map[string]*vm.Program
+	plain map[string]*vm.Program // programs compiled without patch
+
+	memo    *Memo
+	key     string // options key of memo entries
+	effects Effects
+	patch   func(src string) ast.Visitor
 }
 
 func NewCache() *Cache {
-	return &Cache{prog: make(map[string]*vm.Program, 128)}
+	return &Cache{prog: make(map[string]*vm.Program, 128), plain: map[string]*vm.Program{}}
+}
+
+// NewSharedCache is a Cache whose results are shared through memo with caches created
+// with the same key, which must identify every option that can change what a source
+// evaluates to (functions, unknown policy, undefined names); effects replays what a
+// memoized evaluation did in the cache that first ran it. If patch is not nil,
+// the visitor it returns for a src is applied when compiling that src; a run of the
+// patched program that fails is repeated without the patch, so errors read as usual.
+func NewSharedCache(memo *Memo, key string, effects Effects, patch func(src string) ast.Visitor) *Cache {
+	c := NewCache()
+	c.memo, c.key, c.effects, c.patch = memo, key, effects, patch
+	return c
 }
 
 func (c *Cache) getOrCompile(src string, opts ...expr.Option) (*vm.Program, error) {
@@ -24,6 +43,9 @@ func (c *Cache) getOrCompile(src string, opts ...expr.Option) (*vm.Program, erro
 	if p, ok := c.prog[src]; ok {
 		return p, nil
 	}
+	if c.patch != nil {
+		opts = append(opts[:len(opts):len(opts)], expr.Patch(c.patch(src)))
+	}
 	p, err := expr.Compile(src, opts...)
 	if err != nil {
 		return nil, err
@@ -31,3 +53,19 @@ func (c *Cache) getOrCompile(src string, opts ...expr.Option) (*vm.Program, erro
 	c.prog[src] = p
 	return p, nil
 }
+
+// getOrCompilePlain compiles src without the cache's patch.
+func (c *Cache) getOrCompilePlain(src string, opts ...expr.Option) (*vm.Program, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if p, ok := c.plain[src]; ok {
+		return p, nil
+	}
+	p, err := expr.Compile(src, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.plain[src] = p
+	return p, nil
+}
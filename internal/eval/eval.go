@@ -4,12 +4,36 @@ import "github.com/expr-lang/expr"
 
 // EvalString compiles (cached) and runs src against env.
 // Returns (value, errorString).
+// With a shared cache (NewSharedCache), a src already evaluated through the same Memo
+// under the same options key is not run again: its result is reused and its effects are replayed.
 func EvalString(src string, env map[string]interface{}, cache *Cache, opts ...expr.Option) (interface{}, string) {
+	if cache.memo == nil {
+		return run(src, env, cache, opts...)
+	}
+	key := memoKey(cache.key, src)
+	if e, ok := cache.memo.lookup(key); ok {
+		cache.effects.Replay(e.effects)
+		return e.val, e.errStr
+	}
+	end := cache.effects.Begin()
+	v, errStr := run(src, env, cache, opts...)
+	if effects, ok := end(); ok {
+		cache.memo.store(key, memoEntry{val: v, errStr: errStr, effects: effects})
+	}
+	return v, errStr
+}
+
+func run(src string, env map[string]interface{}, cache *Cache, opts ...expr.Option) (interface{}, string) {
 	p, err := cache.getOrCompile(src, opts...)
 	if err != nil {
 		return nil, err.Error()
 	}
 	v, err := expr.Run(p, env)
+	if err != nil && cache.patch != nil {
+		if p, cerr := cache.getOrCompilePlain(src, opts...); cerr == nil {
+			v, err = expr.Run(p, env)
+		}
+	}
 	if err != nil {
 		return nil, err.Error()
 	}
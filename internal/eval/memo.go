@@ -0,0 +1,69 @@
+package eval
+
+import "sync"
+
+// Memo shares evaluation results between caches that evaluate against the same env, so a
+// source seen by several traces is evaluated once. Entries are keyed by source and by
+// the options key of the cache (see NewSharedCache): caches with different keys never
+// see each other's results.
+type Memo struct {
+	mu       sync.Mutex
+	entries  map[string]memoEntry
+	requests int
+	computed int
+}
+
+type memoEntry struct {
+	val     interface{}
+	errStr  string
+	effects interface{}
+}
+
+func NewMemo() *Memo {
+	return &Memo{entries: make(map[string]memoEntry, 256)}
+}
+
+// Effects captures and replays the side effects of an evaluation (e.g. Cond outcomes
+// recorded by the functions it called), so a memo hit leaves the caller in the same state
+// as evaluating would have.
+type Effects interface {
+	// Begin starts capturing; end returns what happened since, or ok=false if the
+	// evaluation had effects that cannot be replayed and must not be memoized.
+	Begin() (end func() (effects interface{}, ok bool))
+	Replay(effects interface{})
+}
+
+// Stats returns how many evaluations were requested through the memo and how many of
+// them actually ran.
+func (m *Memo) Stats() (requests, computed int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests, m.computed
+}
+
+func memoKey(opts, src string) string { return opts + "\x00" + src }
+
+func (m *Memo) lookup(key string) (memoEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests++
+	e, ok := m.entries[key]
+	if !ok {
+		m.computed++
+	}
+	return e, ok
+}
+
+func (m *Memo) store(key string, e memoEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = e
+}
+
+// Seed stores a result known from elsewhere (e.g. a previous trace of the same source
+// against an env that differs only where src does not read), as if src had been
+// evaluated with these effects by a cache with options key opts. Seeding does not count
+// in Stats.
+func (m *Memo) Seed(opts, src string, val interface{}, errStr string, effects interface{}) {
+	m.store(memoKey(opts, src), memoEntry{val: val, errStr: errStr, effects: effects})
+}
@@ -0,0 +1,69 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/expr-lang/expr"
+)
+
+type noEffects struct{}
+
+func (noEffects) Begin() func() (interface{}, bool) {
+	return func() (interface{}, bool) { return nil, true }
+}
+func (noEffects) Replay(interface{}) {}
+
+func TestMemoKeyedByOptions(t *testing.T) {
+	env := map[string]interface{}{"x": 2}
+	memo := NewMemo()
+	calls := 0
+	double := func(factor int) expr.Option {
+		return expr.Function("f", func(params ...any) (any, error) {
+			calls++
+			return params[0].(int) * factor, nil
+		})
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		factor   int
+		want     int
+		computed int
+	}{
+		{"first", "f=2", 2, 4, 1},
+		{"same key is shared", "f=2", 2, 4, 1},
+		{"other key is not", "f=3", 3, 6, 2},
+		{"first key still shared", "f=2", 3, 4, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewSharedCache(memo, tt.key, noEffects{}, nil)
+			v, errStr := EvalString(`f(x)`, env, c, expr.Env(env), double(tt.factor))
+			if errStr != "" {
+				t.Fatalf("EvalString: %s", errStr)
+			}
+			if v != tt.want {
+				t.Errorf("f(x) = %v, want %v", v, tt.want)
+			}
+			if _, computed := memo.Stats(); computed != tt.computed || calls != tt.computed {
+				t.Errorf("computed %d, f called %d times, want %d", computed, calls, tt.computed)
+			}
+		})
+	}
+}
+
+func TestMemoSeed(t *testing.T) {
+	memo := NewMemo()
+	memo.Seed("k", `x > 1`, false, "", nil)
+	env := map[string]interface{}{"x": 2}
+
+	seeded := NewSharedCache(memo, "k", noEffects{}, nil)
+	if v, _ := EvalString(`x > 1`, env, seeded, expr.Env(env)); v != false {
+		t.Errorf("seeded result = %v, want false", v)
+	}
+	other := NewSharedCache(memo, "other", noEffects{}, nil)
+	if v, _ := EvalString(`x > 1`, env, other, expr.Env(env)); v != true {
+		t.Errorf("result under another key = %v, want true", v)
+	}
+}
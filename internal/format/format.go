@@ -2,7 +2,6 @@ package format
 
 import (
 	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/expr-lang/expr/ast"
@@ -10,10 +9,18 @@ import (
 
 // Formatter produces a canonical-ish expression string for hashing/tracing.
 // This is designed for explainability, not perfect round-trip printing.
-type Formatter struct{}
+type Formatter struct {
+	legacy bool
+}
 
 func New() *Formatter { return &Formatter{} }
 
+// NewLegacy returns a Formatter that prints as releases before operator grouping,
+// quoted member keys, listed constant sets and float literals with a decimal point did.
+// It exists to recompute fingerprints stored by those releases; its output may not parse
+// back.
+func NewLegacy() *Formatter { return &Formatter{legacy: true} }
+
 func (f *Formatter) Format(node ast.Node) string {
 	switch n := node.(type) {
 	case *ast.NilNode:
@@ -23,44 +30,53 @@ func (f *Formatter) Format(node ast.Node) string {
 	case *ast.IntegerNode:
 		return fmt.Sprintf("%d", n.Value)
 	case *ast.FloatNode:
-		return fmt.Sprintf("%v", n.Value)
+		if f.legacy {
+			return fmt.Sprintf("%v", n.Value)
+		}
+		return formatFloat(n.Value)
 	case *ast.BoolNode:
 		return fmt.Sprintf("%v", n.Value)
 	case *ast.StringNode:
 		return fmt.Sprintf(`"%s"`, escapeString(n.Value))
 
 	case *ast.ConstantNode:
-		switch v := n.Value.(type) {
-		case string:
-			return fmt.Sprintf(`"%s"`, escapeString(v))
-		case map[string]struct{}:
-			keys := make([]string, 0, len(v))
-			for k := range v {
-				keys = append(keys, k)
-			}
-			sort.Strings(keys)
-			parts := make([]string, 0, len(keys))
-			for _, k := range keys {
-				parts = append(parts, fmt.Sprintf(`"%s"`, escapeString(k)))
-			}
-			return "[" + strings.Join(parts, ", ") + "]"
-		case []byte:
-			return formatBytesLiteral(v)
-		default:
-			return fmt.Sprintf("%v", n.Value)
+		if f.legacy {
+			return legacyConstant(n.Value)
 		}
+		return formatConstant(n.Value)
 
 	case *ast.UnaryNode:
+		inner := f.Format(n.Node)
+		if !f.legacy && unaryNeedsParens(n) {
+			inner = "(" + inner + ")"
+		}
 		if n.Operator == "not" {
-			return "not " + f.Format(n.Node)
+			return "not " + inner
 		}
-		return n.Operator + f.Format(n.Node)
+		return n.Operator + inner
 
 	case *ast.BinaryNode:
-		return fmt.Sprintf("%s %s %s", f.Format(n.Left), n.Operator, f.Format(n.Right))
+		lhs, rhs := f.Format(n.Left), f.Format(n.Right)
+		lwrap, rwrap := binaryNeedsParens(n)
+		if f.legacy {
+			lwrap, rwrap = false, false
+		}
+		if lwrap {
+			lhs = "(" + lhs + ")"
+		}
+		if rwrap {
+			rhs = "(" + rhs + ")"
+		}
+		return fmt.Sprintf("%s %s %s", lhs, n.Operator, rhs)
 
 	case *ast.ConditionalNode:
-		return fmt.Sprintf("%s ? %s : %s", f.Format(n.Cond), f.Format(n.Exp1), f.Format(n.Exp2))
+		parts := [3]string{f.Format(n.Cond), f.Format(n.Exp1), f.Format(n.Exp2)}
+		for i, c := range []ast.Node{n.Cond, n.Exp1, n.Exp2} {
+			if _, ok := c.(*ast.ConditionalNode); ok && !f.legacy {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		}
+		return fmt.Sprintf("%s ? %s : %s", parts[0], parts[1], parts[2])
 
 	case *ast.SequenceNode:
 		parts := make([]string, 0, len(n.Nodes))
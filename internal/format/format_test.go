@@ -0,0 +1,195 @@
+package format
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+)
+
+func format(t *testing.T, src string) string {
+	t.Helper()
+	tree, err := parser.Parse(src)
+	if err != nil {
+		t.Fatalf("parse %q: %v", src, err)
+	}
+	return New().Format(tree.Node)
+}
+
+func TestFormatGrouping(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(a || b) && c`, `(a || b) && c`},
+		{`a || b && c`, `a || (b && c)`},
+		{`a && b && c`, `a && b && c`},
+		{`a - (b - c)`, `a - (b - c)`},
+		{`(a - b) - c`, `a - b - c`},
+		{`a * (b + c)`, `a * (b + c)`},
+		{`2 ** (3 ** 2)`, `2 ** 3 ** 2`},
+		{`(2 ** 3) ** 2`, `(2 ** 3) ** 2`},
+		{`not (a && b)`, `not (a && b)`},
+		{`-(a + b)`, `-(a + b)`},
+		{`(a ?? b) ?? c`, `(a ?? b) ?? c`},
+		{`(a ? b : c) ? d : e`, `(a ? b : c) ? d : e`},
+		{`(a + b).c`, `(a + b).c`},
+		{`a == (b == c)`, `a == (b == c)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got := format(t, tt.src)
+			if got != tt.want {
+				t.Fatalf("Format = %q, want %q", got, tt.want)
+			}
+			// the output must parse back to the same tree
+			if again := format(t, got); again != got {
+				t.Errorf("Format(Format) = %q, want %q", again, got)
+			}
+		})
+	}
+}
+
+func TestFormatMember(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`user.Name`, `user.Name`},
+		{`user["Name"]`, `user.Name`},
+		{`comment["odd key"]`, `comment["odd key"]`},
+		{`m["a-b"].c`, `m["a-b"].c`},
+		{`m["1st"]`, `m["1st"]`},
+		{`user?.["odd key"]`, `user?.["odd key"]`},
+		{`user?.Profile.Age`, `user?.Profile.Age`},
+		{`tweets[0].Len`, `tweets[0].Len`},
+		{`user[key]`, `user[key]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got := format(t, tt.src)
+			if got != tt.want {
+				t.Fatalf("Format = %q, want %q", got, tt.want)
+			}
+			if again := format(t, got); again != got {
+				t.Errorf("Format(Format) = %q, want %q", again, got)
+			}
+		})
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`x > 100.0`, `x > 100.0`},
+		{`x > 1.5`, `x > 1.5`},
+		{`x > 1e21`, `x > 1e+21`},
+		{`x > -2.0`, `x > -2.0`},
+		{`x > 100`, `x > 100`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			if got := format(t, tt.src); got != tt.want {
+				t.Fatalf("Format = %q, want %q", got, tt.want)
+			}
+			// the literal must parse back as the same kind of number
+			if got, want := literalNode(t, tt.want), literalNode(t, tt.src); reflect.TypeOf(got) != reflect.TypeOf(want) {
+				t.Errorf("literal parses back as %T, want %T", got, want)
+			}
+		})
+	}
+}
+
+// literalNode returns the number literal on the right of a comparison.
+func literalNode(t *testing.T, src string) ast.Node {
+	t.Helper()
+	tree, err := parser.Parse(src)
+	if err != nil {
+		t.Fatalf("parse %q: %v", src, err)
+	}
+	n := tree.Node.(*ast.BinaryNode).Right
+	if un, ok := n.(*ast.UnaryNode); ok {
+		n = un.Node
+	}
+	return n
+}
+
+func TestFormatConstant(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"string", "a\"b", `"a\"b"`},
+		{"int", 3, `3`},
+		{"nil", nil, `nil`},
+		{"string set", map[string]struct{}{"b": {}, "a": {}}, `["a", "b"]`},
+		{"int set", map[int]struct{}{10: {}, 2: {}, 1: {}}, `[1, 2, 10]`},
+		{"float set", map[float64]struct{}{2.5: {}, -1: {}}, `[-1.0, 2.5]`},
+		{"array", []interface{}{1, "a", nil}, `[1, "a", nil]`},
+		{"nested", []interface{}{[]int{1, 2}}, `[[1, 2]]`},
+		{"bytes", []byte("ab"), formatBytesLiteral([]byte("ab"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New().Format(&ast.ConstantNode{Value: tt.value})
+			if got != tt.want {
+				t.Fatalf("Format = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFormatOptimized formats compiled trees, where the optimizer folded `in` lists
+// into set constants: they must print as the literals they came from.
+func TestFormatOptimized(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`x in [10, 2, 1]`, `x in [1, 2, 10]`},
+		{`s in ["b", "a"]`, `s in ["a", "b"]`},
+		{`x in [1, 2, 3] && s in ["a"]`, `x in [1, 2, 3] && s in ["a"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			program, err := expr.Compile(tt.src, expr.Env(map[string]interface{}{"x": 0, "s": ""}))
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			if got := New().Format(program.Node()); got != tt.want {
+				t.Fatalf("Format = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFormatLegacy pins NewLegacy to the output of releases before grouping, quoted
+// member keys and listed int sets.
+func TestFormatLegacy(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(a || b) && c`, `a || b && c`},
+		{`!(x > 1)`, `!x > 1`},
+		{`(x + 1) * 2 > 7`, `x + 1 * 2 > 7`},
+		{`(a ? b : c) + 1`, `a ? b : c + 1`},
+		{`comment["odd key"]`, `comment.odd key`},
+		{`x in [10, 2, 1]`, `x in map[1:{} 2:{} 10:{}]`},
+		{`s in ["b", "a"]`, `s in ["a", "b"]`},
+		{`x > 100.0`, `x > 100`},
+		{`user.Age >= 18 && s startsWith "a"`, `user.Age >= 18 && s startsWith "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			env := map[string]interface{}{"x": 0, "s": ""}
+			program, err := expr.Compile(tt.src, expr.Env(env), expr.AllowUndefinedVariables())
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			if got := NewLegacy().Format(program.Node()); got != tt.want {
+				t.Fatalf("Format = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,56 @@ package format
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser/operator"
+	"github.com/expr-lang/expr/parser/utils"
 )
 
+// unaryNeedsParens mirrors expr's own printer: wrap lower-precedence operands.
+func unaryNeedsParens(n *ast.UnaryNode) bool {
+	switch b := n.Node.(type) {
+	case *ast.BinaryNode:
+		return operator.Binary[b.Operator].Precedence < operator.Unary[n.Operator].Precedence
+	case *ast.ConditionalNode:
+		return true
+	}
+	return false
+}
+
+// binaryNeedsParens mirrors expr's own printer so formatted sources keep their grouping.
+func binaryNeedsParens(n *ast.BinaryNode) (lwrap, rwrap bool) {
+	self := operator.Binary[n.Operator]
+
+	switch l := n.Left.(type) {
+	case *ast.UnaryNode:
+		lwrap = operator.Unary[l.Operator].Precedence < self.Precedence
+	case *ast.BinaryNode:
+		lp := operator.Binary[l.Operator]
+		lwrap = lp.Precedence < self.Precedence ||
+			(lp.Precedence == self.Precedence && self.Associativity == operator.Right) ||
+			l.Operator == "??" ||
+			(operator.IsBoolean(l.Operator) && n.Operator != l.Operator)
+	case *ast.ConditionalNode:
+		lwrap = true
+	}
+
+	switch r := n.Right.(type) {
+	case *ast.BinaryNode:
+		rp := operator.Binary[r.Operator]
+		rwrap = rp.Precedence < self.Precedence ||
+			(rp.Precedence == self.Precedence && self.Associativity == operator.Left) ||
+			(operator.IsBoolean(r.Operator) && n.Operator != r.Operator)
+	case *ast.ConditionalNode:
+		rwrap = true
+	}
+	return lwrap, rwrap
+}
+
 func escapeString(s string) string {
 	s = strings.ReplaceAll(s, `\`, `\\`)
 	s = strings.ReplaceAll(s, `"`, `\"`)
@@ -36,10 +81,88 @@ func formatBytesLiteral(b []byte) string {
 	return sb.String()
 }
 
+// formatConstant prints a value the optimizer folded into a ConstantNode back as a
+// literal: sets built for `in` (map[T]struct{}) and constant arrays become array
+// literals, with set members sorted (numbers by value, anything else by its literal).
+func formatConstant(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return fmt.Sprintf(`"%s"`, escapeString(v))
+	case []byte:
+		return formatBytesLiteral(v)
+	case float64:
+		return formatFloat(v)
+	case float32:
+		return formatFloat(float64(v))
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Elem() != reflect.TypeOf(struct{}{}) {
+			break
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, formatConstant(k.Interface()))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			parts = append(parts, formatConstant(rv.Index(i).Interface()))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// formatFloat prints a float so it parses back as one: integral values keep a decimal
+// point (`100.0`, not `100`).
+func formatFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// legacyConstant is formatConstant as NewLegacy prints it: string sets as sorted lists,
+// anything else (int sets included) as Go prints it.
+func legacyConstant(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf(`"%s"`, escapeString(v))
+	case map[string]struct{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf(`"%s"`, escapeString(k)))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case []byte:
+		return formatBytesLiteral(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
 func formatMember(f *Formatter, n *ast.MemberNode) string {
 	base := f.Format(n.Node)
+	switch n.Node.(type) {
+	case *ast.BinaryNode, *ast.UnaryNode, *ast.ConditionalNode:
+		if !f.legacy {
+			base = "(" + base + ")"
+		}
+	}
 
-	if sn, ok := n.Property.(*ast.StringNode); ok && !n.Method {
+	if sn, ok := n.Property.(*ast.StringNode); ok && (f.legacy && !n.Method || !f.legacy && utils.IsValidIdentifier(sn.Value)) {
 		if n.Optional {
 			return base + "?." + sn.Value
 		}
@@ -65,3 +188,25 @@ func formatSlice(f *Formatter, n *ast.SliceNode) string {
 	}
 	return fmt.Sprintf("%s[%s:%s]", base, from, to)
 }
+
+// lessKey orders set members: numbers by value, the rest by their printed literal.
+func lessKey(a, b reflect.Value) bool {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			return x < y
+		}
+	}
+	return formatConstant(a.Interface()) < formatConstant(b.Interface())
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
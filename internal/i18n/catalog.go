@@ -0,0 +1,242 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Catalog maps reason codes to message templates per locale.
+//
+// Lookups follow a fallback chain: explicit chains set with SetFallback, otherwise the
+// locale's parent tags (de-AT -> de), then the default locale. An entry can hold several
+// plural forms; the locale's plural rule picks one from a count.
+type Catalog struct {
+	mu        sync.RWMutex
+	def       string
+	messages  map[string]map[string][]string // locale -> reason -> forms
+	plurals   map[string]*PluralRule         // locale -> rule (overrides the built-in)
+	fallbacks map[string][]string
+}
+
+func New(defaultLocale string) *Catalog {
+	return &Catalog{
+		def:       Canonical(defaultLocale),
+		messages:  map[string]map[string][]string{},
+		plurals:   map[string]*PluralRule{},
+		fallbacks: map[string][]string{},
+	}
+}
+
+// Set registers the message forms for reason in locale. A single form is used for every count.
+func (c *Catalog) Set(locale, reason string, forms ...string) {
+	c.SetContext(locale, "", reason, forms...)
+}
+
+// SetContext registers the message forms for reason in locale under a context (gettext
+// msgctxt), so the same reason can read differently per context. An empty context is Set.
+func (c *Catalog) SetContext(locale, context, reason string, forms ...string) {
+	locale, reason = Canonical(locale), key(context, reason)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.messages[locale]
+	if !ok {
+		m = map[string][]string{}
+		c.messages[locale] = m
+	}
+	m[reason] = forms
+}
+
+// SetPluralRule overrides the plural rule for locale (see ParsePluralRule).
+func (c *Catalog) SetPluralRule(locale string, rule *PluralRule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.plurals[Canonical(locale)] = rule
+}
+
+// SetFallback replaces the fallback chain tried after locale itself.
+// The default locale is always tried last.
+func (c *Catalog) SetFallback(locale string, chain ...string) {
+	canonical := make([]string, len(chain))
+	for i, loc := range chain {
+		canonical[i] = Canonical(loc)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fallbacks[Canonical(locale)] = canonical
+}
+
+// Lookup returns the message template for reason in locale, following the fallback chain.
+// n is the count used to select a plural form.
+func (c *Catalog) Lookup(locale, reason string, n int) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, loc := range c.chain(Canonical(locale), true) {
+		if forms, ok := c.messages[loc][reason]; ok && len(forms) > 0 {
+			return forms[c.pluralIndex(loc, n, len(forms))], true
+		}
+	}
+	return "", false
+}
+
+// LookupContext is Lookup for a context (see SetContext). In each locale of the chain,
+// a message for the context wins over one without.
+func (c *Catalog) LookupContext(locale, context, reason string, n int) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, loc := range c.chain(Canonical(locale), true) {
+		for _, k := range []string{key(context, reason), reason} {
+			if forms, ok := c.messages[loc][k]; ok && len(forms) > 0 {
+				return forms[c.pluralIndex(loc, n, len(forms))], true
+			}
+		}
+	}
+	return "", false
+}
+
+// key joins a context and a reason the way gettext does, with an EOT between them.
+func key(context, reason string) string {
+	if context == "" {
+		return reason
+	}
+	return context + "\x04" + reason
+}
+
+// Translated reports whether reason has a message in locale or its fallbacks,
+// not counting the default locale (unless locale falls under it).
+func (c *Catalog) Translated(locale, reason string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, loc := range c.chain(Canonical(locale), false) {
+		if forms, ok := c.messages[loc][reason]; ok && len(forms) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Locales returns the locales that have at least one message, sorted.
+func (c *Catalog) Locales() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make([]string, 0, len(c.messages))
+	for loc := range c.messages {
+		out = append(out, loc)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (c *Catalog) chain(locale string, withDefault bool) []string {
+	out := []string{locale}
+	if fb, ok := c.fallbacks[locale]; ok {
+		out = append(out, fb...)
+	} else {
+		for p := parent(locale); p != ""; p = parent(p) {
+			out = append(out, p)
+		}
+	}
+	if withDefault && c.def != "" {
+		out = append(out, c.def)
+	}
+	return out
+}
+
+func (c *Catalog) pluralIndex(locale string, n, forms int) int {
+	if forms == 1 {
+		return 0
+	}
+	rule := builtinRule(locale)
+	for loc := locale; loc != ""; loc = parent(loc) {
+		if r, ok := c.plurals[loc]; ok {
+			rule = r
+			break
+		}
+	}
+	idx := rule.Index(n)
+	if idx < 0 || idx >= forms {
+		return forms - 1
+	}
+	return idx
+}
+
+// jsonCatalog is the on-disk JSON format, one locale per document:
+//
+//	{
+//	  "locale": "de",
+//	  "plural": "n != 1",
+//	  "messages": {
+//	    "AGE_TOO_LOW": "Sie müssen mindestens {{.Right}} sein",
+//	    "NO_TWEETS": ["Mindestens {{.Right}} Tweet", "Mindestens {{.Right}} Tweets"]
+//	  }
+//	}
+//
+// A message is a string or a list of plural forms; "plural" is optional.
+type jsonCatalog struct {
+	Locale   string                     `json:"locale"`
+	Plural   string                     `json:"plural,omitempty"`
+	Messages map[string]json.RawMessage `json:"messages"`
+}
+
+// LoadJSON adds the messages of one JSON catalog document.
+func (c *Catalog) LoadJSON(r io.Reader) error {
+	var doc jsonCatalog
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return fmt.Errorf("catalog json: %w", err)
+	}
+	if doc.Locale == "" {
+		return fmt.Errorf("catalog json: missing locale")
+	}
+	if doc.Plural != "" {
+		rule, err := ParsePluralRule(doc.Plural)
+		if err != nil {
+			return fmt.Errorf("catalog json %s: %w", doc.Locale, err)
+		}
+		c.SetPluralRule(doc.Locale, rule)
+	}
+	for reason, raw := range doc.Messages {
+		var one string
+		if err := json.Unmarshal(raw, &one); err == nil {
+			c.Set(doc.Locale, reason, one)
+			continue
+		}
+		var forms []string
+		if err := json.Unmarshal(raw, &forms); err != nil {
+			return fmt.Errorf("catalog json %s: message %s must be a string or a list of strings", doc.Locale, reason)
+		}
+		c.Set(doc.Locale, reason, forms...)
+	}
+	return nil
+}
+
+// Canonical normalizes a locale tag: "de_at" -> "de-AT".
+func Canonical(locale string) string {
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	for i, p := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(p)
+		case len(p) == 2:
+			parts[i] = strings.ToUpper(p)
+		default:
+			parts[i] = p
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+func parent(locale string) string {
+	i := strings.LastIndex(locale, "-")
+	if i < 0 {
+		return ""
+	}
+	return locale[:i]
+}
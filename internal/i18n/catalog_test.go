@@ -0,0 +1,114 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupFallback(t *testing.T) {
+	c := New("en")
+	c.Set("en", "MINOR", "too young")
+	c.Set("de", "MINOR", "zu jung")
+	c.Set("de_at", "BANNED", "gesperrt (AT)")
+
+	tests := []struct {
+		locale, reason, want string
+	}{
+		{"de-AT", "BANNED", "gesperrt (AT)"},
+		{"de-AT", "MINOR", "zu jung"},
+		{"fr", "MINOR", "too young"},
+	}
+	for _, tt := range tests {
+		got, ok := c.Lookup(tt.locale, tt.reason, 1)
+		if !ok || got != tt.want {
+			t.Errorf("Lookup(%s, %s) = %q, %v, want %q", tt.locale, tt.reason, got, ok, tt.want)
+		}
+	}
+	if _, ok := c.Lookup("de", "UNKNOWN", 1); ok {
+		t.Error("Lookup of a missing reason: want not found")
+	}
+	if c.Translated("fr", "MINOR") {
+		t.Error("Translated(fr, MINOR) counts the default locale")
+	}
+	if got := strings.Join(c.Locales(), ","); got != "de,de-AT,en" {
+		t.Errorf("Locales = %s", got)
+	}
+}
+
+func TestSetFallback(t *testing.T) {
+	c := New("en")
+	c.Set("en", "MINOR", "too young")
+	c.Set("fr", "MINOR", "trop jeune")
+
+	chain := []string{"FR"}
+	c.SetFallback("fr_ca", chain...)
+	if chain[0] != "FR" {
+		t.Errorf("SetFallback changed the caller's chain to %v", chain)
+	}
+	if got, _ := c.Lookup("fr-CA", "MINOR", 1); got != "trop jeune" {
+		t.Errorf("Lookup(fr-CA) = %q, want the fr fallback", got)
+	}
+}
+
+func TestPluralForms(t *testing.T) {
+	c := New("en")
+	c.Set("en", "TWEETS", "one tweet", "{{.Count}} tweets")
+	c.Set("ru", "TWEETS", "твит", "твита", "твитов")
+	tests := []struct {
+		locale string
+		n      int
+		want   string
+	}{
+		{"en", 1, "one tweet"},
+		{"en", 3, "{{.Count}} tweets"},
+		{"ru", 2, "твита"},
+		{"ru", 5, "твитов"},
+	}
+	for _, tt := range tests {
+		if got, _ := c.Lookup(tt.locale, "TWEETS", tt.n); got != tt.want {
+			t.Errorf("Lookup(%s, %d) = %q, want %q", tt.locale, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestLookupContext(t *testing.T) {
+	c := New("en")
+	c.Set("en", "MINOR", "too young")
+	c.Set("de", "MINOR", "zu jung")
+	c.SetContext("de", "c_alcohol", "MINOR", "zu jung für Alkohol")
+
+	tests := []struct {
+		locale, context, want string
+	}{
+		{"de", "c_alcohol", "zu jung für Alkohol"},
+		{"de", "c_vote", "zu jung"},
+		{"de-AT", "c_alcohol", "zu jung für Alkohol"},
+		{"en", "c_alcohol", "too young"},
+	}
+	for _, tt := range tests {
+		if got, _ := c.LookupContext(tt.locale, tt.context, "MINOR", 1); got != tt.want {
+			t.Errorf("LookupContext(%s, %s) = %q, want %q", tt.locale, tt.context, got, tt.want)
+		}
+	}
+	if got, _ := c.Lookup("de", "MINOR", 1); got != "zu jung" {
+		t.Errorf("Lookup without context = %q", got)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	c := New("en")
+	err := c.LoadJSON(strings.NewReader(`{
+		"locale": "de",
+		"plural": "n != 1",
+		"messages": {"MINOR": "zu jung", "TWEETS": ["ein Tweet", "{{.Count}} Tweets"]}
+	}`))
+	if err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	if got, _ := c.Lookup("de", "TWEETS", 2); got != "{{.Count}} Tweets" {
+		t.Errorf("Lookup(TWEETS, 2) = %q", got)
+	}
+	if err := c.LoadJSON(strings.NewReader(`{"messages": {}}`)); err == nil {
+		t.Error("LoadJSON without locale: want error")
+	}
+}
@@ -0,0 +1,90 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// PluralRule maps a count to a plural form index.
+// Rules are gettext Plural-Forms expressions over n, evaluated with expr:
+//
+//	n != 1
+//	n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2
+type PluralRule struct {
+	src  string
+	prog *vm.Program
+}
+
+// ParsePluralRule compiles a plural expression over n.
+func ParsePluralRule(src string) (*PluralRule, error) {
+	p, err := expr.Compile(src, expr.Env(map[string]interface{}{"n": 0}))
+	if err != nil {
+		return nil, fmt.Errorf("plural rule %q: %w", src, err)
+	}
+	return &PluralRule{src: src, prog: p}, nil
+}
+
+// Index evaluates the rule for n. Boolean results map to 0/1; errors map to 0.
+func (r *PluralRule) Index(n int) int {
+	v, err := expr.Run(r.prog, map[string]interface{}{"n": n})
+	if err != nil {
+		return 0
+	}
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return 1
+		}
+		return 0
+	case int:
+		return x
+	default:
+		return 0
+	}
+}
+
+func (r *PluralRule) String() string { return r.src }
+
+// builtinSources holds plural rules by language (from the gettext/CLDR tables).
+var builtinSources = map[string]string{
+	"en": "n != 1",
+	"de": "n != 1",
+	"nl": "n != 1",
+	"es": "n != 1",
+	"it": "n != 1",
+	"pt": "n != 1",
+	"fr": "n > 1",
+	"tr": "n != 1",
+	"ru": "n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2",
+	"uk": "n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2",
+	"pl": "n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2",
+	"cs": "n==1 ? 0 : n>=2 && n<=4 ? 1 : 2",
+	"ar": "n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5",
+	"ja": "0",
+	"zh": "0",
+	"ko": "0",
+}
+
+var builtinRules = func() map[string]*PluralRule {
+	out := make(map[string]*PluralRule, len(builtinSources))
+	for lang, src := range builtinSources {
+		r, err := ParsePluralRule(src)
+		if err != nil {
+			panic(err)
+		}
+		out[lang] = r
+	}
+	return out
+}()
+
+// builtinRule returns the rule for locale's language, defaulting to the English rule.
+func builtinRule(locale string) *PluralRule {
+	lang, _, _ := strings.Cut(locale, "-")
+	if r, ok := builtinRules[lang]; ok {
+		return r
+	}
+	return builtinRules["en"]
+}
@@ -0,0 +1,44 @@
+package i18n
+
+import "testing"
+
+func TestBuiltinPluralRules(t *testing.T) {
+	tests := []struct {
+		locale string
+		n      int
+		want   int
+	}{
+		{"en", 0, 1}, {"en", 1, 0}, {"en", 2, 1},
+		{"de-AT", 1, 0}, {"de-AT", 5, 1},
+		{"fr", 0, 0}, {"fr", 1, 0}, {"fr", 2, 1},
+		{"ru", 1, 0}, {"ru", 3, 1}, {"ru", 5, 2}, {"ru", 11, 2}, {"ru", 21, 0}, {"ru", 22, 1},
+		{"pl", 1, 0}, {"pl", 2, 1}, {"pl", 12, 2}, {"pl", 22, 1},
+		{"cs", 1, 0}, {"cs", 4, 1}, {"cs", 5, 2},
+		{"ar", 0, 0}, {"ar", 1, 1}, {"ar", 2, 2}, {"ar", 5, 3}, {"ar", 11, 4}, {"ar", 100, 5},
+		{"ja", 1, 0}, {"ja", 7, 0},
+		{"xx", 1, 0}, {"xx", 2, 1}, // unknown languages use the English rule
+	}
+	for _, tt := range tests {
+		if got := builtinRule(tt.locale).Index(tt.n); got != tt.want {
+			t.Errorf("%s: Index(%d) = %d, want %d", tt.locale, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestParsePluralRule(t *testing.T) {
+	r, err := ParsePluralRule("n == 1 ? 0 : n < 5 ? 1 : 2")
+	if err != nil {
+		t.Fatalf("ParsePluralRule: %v", err)
+	}
+	for n, want := range map[int]int{1: 0, 3: 1, 9: 2} {
+		if got := r.Index(n); got != want {
+			t.Errorf("Index(%d) = %d, want %d", n, got, want)
+		}
+	}
+	if r.String() != "n == 1 ? 0 : n < 5 ? 1 : 2" {
+		t.Errorf("String = %q", r.String())
+	}
+	if _, err := ParsePluralRule("n +"); err == nil {
+		t.Error("ParsePluralRule of a broken rule: want error")
+	}
+}
@@ -0,0 +1,192 @@
+package i18n
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LoadPO adds the translations of a gettext .po file. msgid is the reason code.
+//
+// The locale comes from the "Language" header unless locale is non-empty. A
+// "Plural-Forms" header overrides the built-in plural rule; msgid_plural entries
+// keep their msgstr[i] forms in order, and msgctxt entries are set under their context
+// (see SetContext). Fuzzy and untranslated entries are ignored.
+func (c *Catalog) LoadPO(r io.Reader, locale string) error {
+	entries, err := parsePO(r)
+	if err != nil {
+		return err
+	}
+
+	var rule *PluralRule
+	for _, e := range entries {
+		if e.id != "" || e.ctxt != "" {
+			continue
+		}
+		hdr := parseHeader(e.str(0))
+		if locale == "" {
+			locale = hdr["Language"]
+		}
+		if pf, ok := hdr["Plural-Forms"]; ok {
+			if rule, err = parsePluralForms(pf); err != nil {
+				return fmt.Errorf("po: %w", err)
+			}
+		}
+	}
+	if locale == "" {
+		return fmt.Errorf("po: no locale given and no Language header")
+	}
+	if rule != nil {
+		c.SetPluralRule(locale, rule)
+	}
+
+	for _, e := range entries {
+		if e.id == "" || e.fuzzy {
+			continue
+		}
+		forms := e.forms()
+		if len(forms) == 0 {
+			continue
+		}
+		c.SetContext(locale, e.ctxt, e.id, forms...)
+	}
+	return nil
+}
+
+type poEntry struct {
+	ctxt  string
+	id    string
+	strs  []string // msgstr, or msgstr[i] by index
+	fuzzy bool
+}
+
+func (e *poEntry) str(i int) string {
+	if i < len(e.strs) {
+		return e.strs[i]
+	}
+	return ""
+}
+
+// forms returns the msgstr forms, or nil if any of them is untranslated.
+func (e *poEntry) forms() []string {
+	for _, s := range e.strs {
+		if s == "" {
+			return nil
+		}
+	}
+	return e.strs
+}
+
+func parsePO(r io.Reader) ([]*poEntry, error) {
+	var (
+		out    []*poEntry
+		cur    *poEntry
+		target *string
+		fuzzy  bool
+		lineNo int
+	)
+	flush := func() {
+		if cur != nil {
+			out = append(out, cur)
+		}
+		cur, target = nil, nil
+	}
+	start := func() {
+		flush()
+		cur = &poEntry{fuzzy: fuzzy}
+		fuzzy = false
+	}
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#,"):
+			fuzzy = fuzzy || strings.Contains(line, "fuzzy")
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, `"`):
+			if target == nil {
+				return nil, fmt.Errorf("po line %d: string continuation without keyword", lineNo)
+			}
+			s, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, fmt.Errorf("po line %d: %w", lineNo, err)
+			}
+			*target += s
+		default:
+			kw, rest, _ := strings.Cut(line, " ")
+			s, err := strconv.Unquote(strings.TrimSpace(rest))
+			if err != nil {
+				return nil, fmt.Errorf("po line %d: %w", lineNo, err)
+			}
+			switch {
+			case kw == "msgctxt":
+				start()
+				cur.ctxt = s
+				target = &cur.ctxt
+			case kw == "msgid":
+				if cur == nil || cur.id != "" || len(cur.strs) > 0 {
+					start()
+				}
+				cur.id = s
+				target = &cur.id
+			case kw == "msgid_plural":
+				target = new(string)
+				*target = s
+			case kw == "msgstr" || strings.HasPrefix(kw, "msgstr["):
+				if cur == nil {
+					return nil, fmt.Errorf("po line %d: msgstr without msgid", lineNo)
+				}
+				i := 0
+				if kw != "msgstr" {
+					i, err = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(kw, "msgstr["), "]"))
+					if err != nil || i < 0 {
+						return nil, fmt.Errorf("po line %d: bad plural index %q", lineNo, kw)
+					}
+				}
+				for len(cur.strs) <= i {
+					cur.strs = append(cur.strs, "")
+				}
+				cur.strs[i] = s
+				target = &cur.strs[i]
+			default:
+				return nil, fmt.Errorf("po line %d: unknown keyword %q", lineNo, kw)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return out, nil
+}
+
+// parseHeader splits the header entry ("Key: value\n" lines).
+func parseHeader(s string) map[string]string {
+	out := map[string]string{}
+	for _, line := range strings.Split(s, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if ok {
+			out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return out
+}
+
+// parsePluralForms extracts the plural expression from
+// "nplurals=3; plural=(n%10==1 ? 0 : 1);".
+func parsePluralForms(s string) (*PluralRule, error) {
+	for _, part := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && strings.TrimSpace(k) == "plural" {
+			return ParsePluralRule(strings.TrimSpace(v))
+		}
+	}
+	return nil, fmt.Errorf("no plural expression in Plural-Forms %q", s)
+}
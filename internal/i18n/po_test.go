@@ -0,0 +1,101 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPO = `# German reasons
+msgid ""
+msgstr ""
+"Language: de\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+msgid "MINOR"
+msgstr "Sie müssen mindestens {{.Right}} sein"
+
+msgctxt "c_alcohol"
+msgid "MINOR"
+msgstr "Zu jung für Alkohol"
+
+msgid "TWEETS"
+msgid_plural "TWEETS"
+msgstr[0] "Mindestens ein Tweet"
+msgstr[1] "Mindestens {{.Right}} "
+"Tweets"
+
+#, fuzzy
+msgid "BANNED"
+msgstr "Gesperrt"
+
+msgid "UNTRANSLATED"
+msgstr ""
+`
+
+func TestLoadPO(t *testing.T) {
+	c := New("en")
+	if err := c.LoadPO(strings.NewReader(testPO), ""); err != nil {
+		t.Fatalf("LoadPO: %v", err)
+	}
+	tests := []struct {
+		name, context, reason string
+		n                     int
+		want                  string
+		ok                    bool
+	}{
+		{"plain", "", "MINOR", 1, "Sie müssen mindestens {{.Right}} sein", true},
+		{"context", "c_alcohol", "MINOR", 1, "Zu jung für Alkohol", true},
+		{"other context falls back", "c_vote", "MINOR", 1, "Sie müssen mindestens {{.Right}} sein", true},
+		{"plural one", "", "TWEETS", 1, "Mindestens ein Tweet", true},
+		{"plural other, continued string", "", "TWEETS", 3, "Mindestens {{.Right}} Tweets", true},
+		{"fuzzy ignored", "", "BANNED", 1, "", false},
+		{"untranslated ignored", "", "UNTRANSLATED", 1, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := c.LookupContext("de", tt.context, tt.reason, tt.n)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("LookupContext = %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLoadPOPluralForms(t *testing.T) {
+	po := `msgid ""
+msgstr "Plural-Forms: nplurals=3; plural=n==1 ? 0 : n==2 ? 1 : 2;\n"
+
+msgid "ITEMS"
+msgid_plural "ITEMS"
+msgstr[0] "one"
+msgstr[1] "two"
+msgstr[2] "many"
+`
+	c := New("en")
+	if err := c.LoadPO(strings.NewReader(po), "xx"); err != nil {
+		t.Fatalf("LoadPO: %v", err)
+	}
+	for n, want := range map[int]string{1: "one", 2: "two", 7: "many"} {
+		if got, _ := c.Lookup("xx", "ITEMS", n); got != want {
+			t.Errorf("Lookup(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestLoadPOErrors(t *testing.T) {
+	tests := map[string]string{
+		"no locale":         "msgid \"A\"\nmsgstr \"a\"\n",
+		"bad keyword":       "msgid \"A\"\nmsgfoo \"a\"\n",
+		"orphan string":     "\"a\"\n",
+		"msgstr alone":      "msgstr \"a\"\n",
+		"bad plural index":  "msgid \"A\"\nmsgstr[x] \"a\"\n",
+		"bad plural header": "msgid \"\"\nmsgstr \"Language: de\\nPlural-Forms: nplurals=2;\\n\"\n",
+	}
+	for name, po := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := New("en").LoadPO(strings.NewReader(po), ""); err == nil {
+				t.Error("LoadPO: want error")
+			}
+		})
+	}
+}
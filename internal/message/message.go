@@ -0,0 +1,78 @@
+package message
+
+import (
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Data is what a reason message template can reference, e.g.
+//
+//	You must be at least {{.Right}} (you are {{.Left}})
+//	Hello {{.Env.user.Name}}
+type Data struct {
+	ID     string
+	Reason string
+	Op     string      // atom operator, e.g. ">="
+	Left   interface{} // evaluated left operand of the atom
+	Right  interface{} // evaluated right operand of the atom
+	Value  interface{} // outcome of the condition
+	Count  int         // selects catalog plural forms: numeric Right, else numeric Left, else 1
+	Env    map[string]interface{}
+}
+
+// Renderer executes message templates, caching parsed templates by source.
+type Renderer struct {
+	mu    sync.Mutex
+	tmpls map[string]*template.Template
+}
+
+func NewRenderer() *Renderer {
+	return &Renderer{tmpls: map[string]*template.Template{}}
+}
+
+// Render executes src against data. Sources without actions are returned as-is. A nil
+// Left, Right or Value prints as "nil".
+func (r *Renderer) Render(src string, data Data) (string, error) {
+	if !strings.Contains(src, "{{") {
+		return src, nil
+	}
+	t, err := r.parse(src)
+	if err != nil {
+		return "", err
+	}
+	data.Left, data.Right, data.Value = nilSafe(data.Left), nilSafe(data.Right), nilSafe(data.Value)
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// missing stands in for a nil operand, which text/template would print as "<no value>".
+// A nil *missing prints as "nil" and is still false in {{if}}.
+type missing struct{}
+
+func (*missing) String() string { return "nil" }
+
+func nilSafe(v interface{}) interface{} {
+	if v == nil {
+		return (*missing)(nil)
+	}
+	return v
+}
+
+func (r *Renderer) parse(src string) (*template.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, ok := r.tmpls[src]; ok {
+		return t, nil
+	}
+	t, err := template.New("message").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	r.tmpls[src] = t
+	return t, nil
+}
@@ -0,0 +1,36 @@
+package message
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		data Data
+		want string
+	}{
+		{"plain", "You are too young", Data{}, "You are too young"},
+		{"operands", "age {{.Left}} vs {{.Right}}", Data{Left: 16, Right: 18}, "age 16 vs 18"},
+		{"nil operand", "age {{.Left}} vs {{.Right}}", Data{Right: 18}, "age nil vs 18"},
+		{"nil is false", "{{if .Left}}age {{.Left}}{{else}}no age{{end}}", Data{}, "no age"},
+		{"env", "Hello {{.Env.user.Name}}", Data{Env: map[string]interface{}{"user": map[string]interface{}{"Name": "Ada"}}}, "Hello Ada"},
+	}
+	r := NewRenderer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Render(tt.src, tt.data)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	if _, err := NewRenderer().Render("{{.Left", Data{}); err == nil {
+		t.Error("Render of a broken template: want error")
+	}
+}
@@ -30,13 +30,30 @@ func IsAtomNode(n ast.Node) bool {
 	}
 }
 
+// IsCondCall reports whether n is a Cond(...) or Cond3(...) call.
 func IsCondCall(n ast.Node) bool {
 	call, ok := n.(*ast.CallNode)
 	if !ok {
 		return false
 	}
 	id, ok := call.Callee.(*ast.IdentifierNode)
-	return ok && id.Value == "Cond"
+	return ok && (id.Value == "Cond" || id.Value == "Cond3")
+}
+
+// RuleCallName returns the rule name of a Rule("name") call; ok is false for anything else.
+func RuleCallName(n ast.Node) (name string, ok bool) {
+	call, isCall := n.(*ast.CallNode)
+	if !isCall || len(call.Arguments) != 1 {
+		return "", false
+	}
+	if id, isID := call.Callee.(*ast.IdentifierNode); !isID || id.Value != "Rule" {
+		return "", false
+	}
+	sn, isStr := call.Arguments[0].(*ast.StringNode)
+	if !isStr {
+		return "", false
+	}
+	return sn.Value, true
 }
 
 // CollectAtoms returns leaf nodes used for atomic tracing.
@@ -58,6 +75,10 @@ func collectAtoms(n ast.Node, out *[]ast.Node) {
 		*out = append(*out, n)
 		return
 	}
+	if _, ok := RuleCallName(n); ok {
+		*out = append(*out, n)
+		return
+	}
 	switch x := n.(type) {
 	case *ast.BinaryNode:
 		collectAtoms(x.Left, out)
@@ -110,3 +131,35 @@ func collectAtoms(n ast.Node, out *[]ast.Node) {
 	default:
 	}
 }
+
+// CondID returns the id of a Cond("id", ...) or Cond3("id", ...) call; ok is false for
+// anything else.
+func CondID(n ast.Node) (id string, ok bool) {
+	if !IsCondCall(n) {
+		return "", false
+	}
+	call := n.(*ast.CallNode)
+	if len(call.Arguments) == 0 {
+		return "", false
+	}
+	sn, isStr := call.Arguments[0].(*ast.StringNode)
+	if !isStr {
+		return "", false
+	}
+	return sn.Value, true
+}
+
+// CondIDs returns the ids of every Cond or Cond3 call in n, in source order.
+func CondIDs(n ast.Node) []string {
+	v := &condIDs{}
+	ast.Walk(&n, v)
+	return v.ids
+}
+
+type condIDs struct{ ids []string }
+
+func (v *condIDs) Visit(node *ast.Node) {
+	if id, ok := CondID(*node); ok {
+		v.ids = append(v.ids, id)
+	}
+}
@@ -8,12 +8,27 @@ import (
 
 // ConditionSpec is metadata attached to an atomic predicate.
 type ConditionSpec struct {
-	ID          string
-	ReasonTrue  string
-	ReasonFalse string
+	ID            string
+	ReasonTrue    string
+	ReasonFalse   string
+	ReasonUnknown string // set to trace the atom three-valued via Cond3
+
+	// Presentation metadata, copied verbatim into the matching trace chunk.
+	Severity    string
+	Tags        []string
+	Description string
+	Priority    int // higher wins when picking the primary reason
+
+	// Message templates (text/template) rendered into the chunk per outcome.
+	MessageTrue    string
+	MessageFalse   string
+	MessageUnknown string
 }
 
 // WrapAtomsWithCond patches the AST by wrapping known atoms with Cond(id, rT, rF, atom).
+// Specs with a ReasonUnknown are wrapped as Cond3(id, rT, rF, rU, op, left, right);
+// compiled with the NilSafe patch, a comparison on a missing value or one that errors
+// at runtime is then recorded as unknown.
 //
 // - fmter is used to canonicalize atoms to strings.
 // - fingerprint must match how the caller builds spec keys.
@@ -106,18 +121,137 @@ func WrapAtomsWithCond(
 			return
 		}
 
-		wrapped := &ast.CallNode{
+		ast.Patch(n, wrapCond(*n, spec))
+	}
+
+	walk(&root)
+	return root
+}
+
+func wrapCond(atom ast.Node, spec ConditionSpec) *ast.CallNode {
+	if spec.ReasonUnknown == "" {
+		return &ast.CallNode{
 			Callee: &ast.IdentifierNode{Value: "Cond"},
 			Arguments: []ast.Node{
 				&ast.StringNode{Value: spec.ID},
 				&ast.StringNode{Value: spec.ReasonTrue},
 				&ast.StringNode{Value: spec.ReasonFalse},
-				*n,
+				atom,
 			},
 		}
-		ast.Patch(n, wrapped)
 	}
 
-	walk(&root)
-	return root
+	bn := atom.(*ast.BinaryNode)
+	if isNilLiteral(bn.Left) || isNilLiteral(bn.Right) {
+		// `x == nil` tests for nil on purpose: keep the atom whole as the predicate.
+		return &ast.CallNode{
+			Callee: &ast.IdentifierNode{Value: "Cond3"},
+			Arguments: []ast.Node{
+				&ast.StringNode{Value: spec.ID},
+				&ast.StringNode{Value: spec.ReasonTrue},
+				&ast.StringNode{Value: spec.ReasonFalse},
+				&ast.StringNode{Value: spec.ReasonUnknown},
+				atom,
+			},
+		}
+	}
+	return &ast.CallNode{
+		Callee: &ast.IdentifierNode{Value: "Cond3"},
+		Arguments: []ast.Node{
+			&ast.StringNode{Value: spec.ID},
+			&ast.StringNode{Value: spec.ReasonTrue},
+			&ast.StringNode{Value: spec.ReasonFalse},
+			&ast.StringNode{Value: spec.ReasonUnknown},
+			&ast.StringNode{Value: bn.Operator},
+			bn.Left,
+			bn.Right,
+		},
+	}
+}
+
+func isNilLiteral(n ast.Node) bool {
+	_, ok := n.(*ast.NilNode)
+	return ok
+}
+
+// NilSafe is an expr patch that turns the member fetches in the operands of Cond3 calls
+// into optional ones (`user?.Profile?.Age`), so a fetch on a nil parent yields nil, which
+// Cond3 records as unknown, instead of failing the rule. It rewrites only the tree being
+// compiled: sources and chunk expressions keep the plain fetches.
+type NilSafe struct{}
+
+func (NilSafe) Visit(node *ast.Node) {
+	call, ok := (*node).(*ast.CallNode)
+	if !ok || len(call.Arguments) != 7 {
+		return
+	}
+	if id, ok := call.Callee.(*ast.IdentifierNode); !ok || id.Value != "Cond3" {
+		return
+	}
+	call.Arguments[5] = nilSafe(call.Arguments[5])
+	call.Arguments[6] = nilSafe(call.Arguments[6])
+}
+
+// nilSafe makes the member fetches of n optional, in place. Fetches inside closures are
+// left alone.
+func nilSafe(n ast.Node) ast.Node {
+	optional := false
+	var walk func(n ast.Node)
+	walk = func(n ast.Node) {
+		switch x := n.(type) {
+		case *ast.MemberNode:
+			if !x.Method {
+				x.Optional, optional = true, true
+			}
+			walk(x.Node)
+			walk(x.Property)
+		case *ast.ChainNode:
+			walk(x.Node)
+		case *ast.UnaryNode:
+			walk(x.Node)
+		case *ast.BinaryNode:
+			walk(x.Left)
+			walk(x.Right)
+		case *ast.CallNode:
+			for _, a := range x.Arguments {
+				walk(a)
+			}
+		case *ast.ArrayNode:
+			for _, e := range x.Nodes {
+				walk(e)
+			}
+		case *ast.SliceNode:
+			walk(x.Node)
+		}
+	}
+	walk(n)
+	if _, chained := n.(*ast.ChainNode); optional && !chained {
+		return &ast.ChainNode{Node: n}
+	}
+	return n
+}
+
+// CondOperands returns the operator and operands of the atom inside a Cond/Cond3 call.
+// ok is false if n is not a Cond call or its predicate is not a binary atom.
+func CondOperands(n ast.Node) (op string, left, right ast.Node, ok bool) {
+	if !IsCondCall(n) {
+		return "", nil, nil, false
+	}
+	args := n.(*ast.CallNode).Arguments
+	switch len(args) {
+	case 4, 5:
+		bn, isBin := args[len(args)-1].(*ast.BinaryNode)
+		if !isBin || !IsAtomNode(bn) {
+			return "", nil, nil, false
+		}
+		return bn.Operator, bn.Left, bn.Right, true
+	case 7:
+		sn, isStr := args[4].(*ast.StringNode)
+		if !isStr {
+			return "", nil, nil, false
+		}
+		return sn.Value, args[5], args[6], true
+	default:
+		return "", nil, nil, false
+	}
 }
@@ -1,18 +1,37 @@
 package util
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+)
 
-// ExtractFirstStringArg best-effort parses Cond("id", ...) to "id".
+// ExtractFirstStringArg best-effort parses Cond("id", ...) or Cond3("id", ...) to "id".
 // Limitation: assumes formatter prints Cond("...") with double quotes.
 func ExtractFirstStringArg(exprStr string) string {
-	p := strings.Index(exprStr, `Cond("`)
+	prefix := `Cond("`
+	p := strings.Index(exprStr, prefix)
+	if q := strings.Index(exprStr, `Cond3("`); q >= 0 && (p < 0 || q < p) {
+		p, prefix = q, `Cond3("`
+	}
 	if p < 0 {
 		return ""
 	}
-	start := p + len(`Cond("`)
+	start := p + len(prefix)
 	end := strings.Index(exprStr[start:], `"`)
 	if end < 0 {
 		return ""
 	}
 	return exprStr[start : start+end]
 }
+
+// ExtractRuleName best-effort parses an expression that is exactly Rule("name") to "name".
+func ExtractRuleName(exprStr string) (string, bool) {
+	if !strings.HasPrefix(exprStr, `Rule("`) || !strings.HasSuffix(exprStr, `")`) {
+		return "", false
+	}
+	name, err := strconv.Unquote(exprStr[len(`Rule(`) : len(exprStr)-1])
+	if err != nil {
+		return "", false
+	}
+	return name, true
+}
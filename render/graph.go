@@ -0,0 +1,122 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aqilarik/ruletrace/ruletrace"
+)
+
+var statusColors = map[string]string{
+	"true":    "#c8e6c9",
+	"false":   "#ffcdd2",
+	"error":   "#ffcdd2",
+	"unknown": "#fff9c4",
+	"skipped": "#eeeeee",
+	"value":   "#e3f2fd",
+}
+
+// nodeLabel names a tree node: operator for inner nodes, ID or expression plus outcome for leaves.
+func nodeLabel(n *ruletrace.TraceNode) (label, st string) {
+	if n.Op != "" {
+		return n.Op, ""
+	}
+	if n.Chunk == nil {
+		return n.Expr, ""
+	}
+	c := n.Chunk
+	st = status(*c)
+	label = c.Expr
+	if c.ID != "" {
+		label = c.ID
+		if c.Reason != "" {
+			label += " · " + c.Reason
+		}
+	}
+	switch st {
+	case "skipped", "unknown", "error":
+		label += "\n" + st
+	default:
+		label += "\n= " + valueString(c.Value)
+	}
+	return label, st
+}
+
+type graphWriter func(id int, n *ruletrace.TraceNode, parent int)
+
+func walkTree(n *ruletrace.TraceNode, visit graphWriter) {
+	next := 0
+	var walk func(n *ruletrace.TraceNode, parent int)
+	walk = func(n *ruletrace.TraceNode, parent int) {
+		id := next
+		next++
+		visit(id, n, parent)
+		for _, c := range n.Children {
+			walk(c, id)
+		}
+	}
+	walk(n, -1)
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// encodeDOT writes the evaluation tree as a Graphviz digraph.
+func encodeDOT(w io.Writer, res ruletrace.TraceResult) error {
+	root, err := res.Tree()
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString("digraph trace {\n")
+	sb.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\", fontname=\"Helvetica\"];\n")
+	walkTree(root, func(id int, n *ruletrace.TraceNode, parent int) {
+		label, st := nodeLabel(n)
+		attrs := fmt.Sprintf(`label="%s"`, dotEscaper.Replace(label))
+		if n.Op != "" {
+			attrs += ", shape=ellipse"
+		}
+		if color, ok := statusColors[st]; ok {
+			attrs += fmt.Sprintf(`, fillcolor="%s"`, color)
+		}
+		fmt.Fprintf(&sb, "  n%d [%s];\n", id, attrs)
+		if parent >= 0 {
+			fmt.Fprintf(&sb, "  n%d -> n%d;\n", parent, id)
+		}
+	})
+	sb.WriteString("}\n")
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "\n", "<br/>", "<", "#lt;", ">", "#gt;")
+
+// encodeMermaid writes the evaluation tree as a Mermaid flowchart.
+func encodeMermaid(w io.Writer, res ruletrace.TraceResult) error {
+	root, err := res.Tree()
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString("flowchart TD\n")
+	walkTree(root, func(id int, n *ruletrace.TraceNode, parent int) {
+		label, st := nodeLabel(n)
+		label = mermaidEscaper.Replace(label)
+		if n.Op != "" {
+			fmt.Fprintf(&sb, "  n%d{\"%s\"}\n", id, label)
+		} else {
+			fmt.Fprintf(&sb, "  n%d[\"%s\"]\n", id, label)
+		}
+		if st != "" {
+			fmt.Fprintf(&sb, "  class n%d st_%s\n", id, st)
+		}
+		if parent >= 0 {
+			fmt.Fprintf(&sb, "  n%d --> n%d\n", parent, id)
+		}
+	})
+	for _, st := range []string{"true", "false", "error", "unknown", "skipped", "value"} {
+		fmt.Fprintf(&sb, "  classDef st_%s fill:%s\n", st, statusColors[st])
+	}
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
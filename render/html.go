@@ -0,0 +1,65 @@
+package render
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/aqilarik/ruletrace/ruletrace"
+)
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"status": status,
+	"value":  valueString,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>ruletrace report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+pre { background: #f5f5f5; padding: .75rem; overflow-x: auto; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ddd; padding: .4rem .6rem; text-align: left; vertical-align: top; }
+th { background: #fafafa; }
+code { font-size: .9em; }
+.true { background: #e8f5e9; }
+.false, .error { background: #ffebee; }
+.unknown { background: #fff8e1; }
+.skipped { color: #999; }
+</style>
+</head>
+<body>
+<h1>Final: {{printf "%v" .Final}}</h1>
+<p>{{.Summary}}</p>
+<p>Mode: {{.Mode}}</p>
+{{if .Input}}<h2>Input</h2>
+<pre>{{.Input}}</pre>{{end}}
+<h2>Source</h2>
+<pre>{{.Source}}</pre>
+{{if .PowerAssert}}<pre>{{.PowerAssert}}</pre>{{end}}
+<h2>Chunks</h2>
+<table>
+<tr><th>#</th><th>ID</th><th>Expression</th><th>Value</th><th>Reason</th><th>Message</th><th>Status</th></tr>
+{{range $i, $c := .Chunks}}<tr class="{{status $c}}">
+<td>{{$i}}</td><td>{{$c.ID}}</td><td><code>{{$c.Expr}}</code></td><td>{{value $c.Value}}</td>
+<td>{{$c.Reason}}{{if $c.Error}}<br><small>{{$c.Error}}</small>{{end}}</td><td>{{$c.Message}}</td><td>{{status $c}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type htmlData struct {
+	ruletrace.TraceResult
+	Summary     string
+	PowerAssert string
+}
+
+// encodeHTML writes a self-contained report (inline CSS, no scripts).
+func encodeHTML(w io.Writer, res ruletrace.TraceResult) error {
+	data := htmlData{TraceResult: res, Summary: res.Summary()}
+	if len(res.Values) > 0 {
+		data.PowerAssert = res.PowerAssert()
+	}
+	return htmlReport.Execute(w, data)
+}
@@ -0,0 +1,30 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/aqilarik/ruletrace/ruletrace"
+)
+
+type jsonChunk struct {
+	Index int `json:"index"`
+	ruletrace.EvalResult
+}
+
+func encodeJSON(w io.Writer, res ruletrace.TraceResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// encodeJSONLines writes one JSON object per chunk, with its position in the trace.
+func encodeJSONLines(w io.Writer, res ruletrace.TraceResult) error {
+	enc := json.NewEncoder(w)
+	for i, c := range res.Chunks {
+		if err := enc.Encode(jsonChunk{Index: i, EvalResult: c}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
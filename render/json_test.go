@@ -0,0 +1,73 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aqilarik/ruletrace/ruletrace"
+)
+
+func TestJSONModeNames(t *testing.T) {
+	sub := ruletrace.TraceResult{Source: "user.Age >= 18", Final: true, Mode: ruletrace.TraceCoarse}
+	res := ruletrace.TraceResult{
+		Source: `Cond("c_adult", "ADULT", "MINOR", is_adult(user))`,
+		Final:  true,
+		Mode:   ruletrace.TraceAtomic,
+		Chunks: []ruletrace.EvalResult{{ID: "c_adult", Expr: "is_adult(user)", Value: true, Sub: &sub}},
+	}
+	var b bytes.Buffer
+	if err := Encode(&b, "json", res); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var got struct {
+		Mode   string `json:"mode"`
+		Chunks []struct {
+			Sub struct {
+				Mode string `json:"mode"`
+			} `json:"sub"`
+		} `json:"chunks"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal: %v\n%s", err, b.String())
+	}
+	if got.Mode != "TraceAtomic" || got.Chunks[0].Sub.Mode != "TraceCoarse" {
+		t.Errorf("modes = %q, %q; want TraceAtomic, TraceCoarse", got.Mode, got.Chunks[0].Sub.Mode)
+	}
+
+	var back ruletrace.TraceResult
+	if err := json.Unmarshal(b.Bytes(), &back); err != nil {
+		t.Fatalf("Unmarshal TraceResult: %v", err)
+	}
+	if back.Mode != ruletrace.TraceAtomic || back.Chunks[0].Sub.Mode != ruletrace.TraceCoarse {
+		t.Errorf("decoded modes = %v, %v", back.Mode, back.Chunks[0].Sub.Mode)
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	env := map[string]interface{}{"user": map[string]interface{}{"Age": 20, "Group": "guest"}}
+	res := ruletrace.New(env).Trace(`user.Group == "admin" || user.Age >= 18`, nil)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"json", `"mode": "TraceAtomic"`},
+		{"jsonl", `"index":1`},
+		{"markdown", "**Mode:** TraceAtomic"},
+		{"html", "Mode: TraceAtomic"},
+		{"dot", "digraph"},
+		{"mermaid", "flowchart"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := Encode(&b, tt.name, res); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if !strings.Contains(b.String(), tt.want) {
+				t.Errorf("output lacks %q:\n%s", tt.want, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aqilarik/ruletrace/ruletrace"
+)
+
+var mdEscaper = strings.NewReplacer("|", `\|`, "\n", " ", "`", "'")
+
+func encodeMarkdown(w io.Writer, res ruletrace.TraceResult) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "**Final:** `%v` · **Mode:** %s\n\n", res.Final, res.Mode)
+	fmt.Fprintf(&sb, "> %s\n\n", res.Summary())
+	fmt.Fprintf(&sb, "```expr\n%s\n```\n\n", res.Source)
+
+	sb.WriteString("| # | ID | Expression | Value | Reason | Status |\n")
+	sb.WriteString("|---|----|------------|-------|--------|--------|\n")
+	for i, c := range res.Chunks {
+		reason := c.Reason
+		if c.Error != "" {
+			reason = c.Error
+		}
+		fmt.Fprintf(&sb, "| %d | %s | `%s` | %s | %s | %s |\n",
+			i+1,
+			mdEscaper.Replace(c.ID),
+			mdEscaper.Replace(c.Expr),
+			mdEscaper.Replace(valueString(c.Value)),
+			mdEscaper.Replace(reason),
+			status(c),
+		)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
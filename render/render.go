@@ -0,0 +1,99 @@
+// Package render encodes ruletrace.TraceResult values into output formats.
+//
+// Built-in encoders are registered under "json", "jsonl", "markdown", "html", "dot"
+// and "mermaid". Register adds custom ones.
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/aqilarik/ruletrace/ruletrace"
+)
+
+// Encoder writes one trace in some output format.
+type Encoder interface {
+	Encode(w io.Writer, res ruletrace.TraceResult) error
+}
+
+// EncoderFunc adapts a function to Encoder.
+type EncoderFunc func(w io.Writer, res ruletrace.TraceResult) error
+
+func (f EncoderFunc) Encode(w io.Writer, res ruletrace.TraceResult) error { return f(w, res) }
+
+var (
+	mu       sync.RWMutex
+	encoders = map[string]Encoder{}
+)
+
+func init() {
+	Register("json", EncoderFunc(encodeJSON))
+	Register("jsonl", EncoderFunc(encodeJSONLines))
+	Register("markdown", EncoderFunc(encodeMarkdown))
+	Register("html", EncoderFunc(encodeHTML))
+	Register("dot", EncoderFunc(encodeDOT))
+	Register("mermaid", EncoderFunc(encodeMermaid))
+}
+
+// Register makes enc available under name, replacing any encoder with that name.
+func Register(name string, enc Encoder) {
+	mu.Lock()
+	defer mu.Unlock()
+	encoders[name] = enc
+}
+
+// Lookup returns the encoder registered under name.
+func Lookup(name string) (Encoder, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	enc, ok := encoders[name]
+	return enc, ok
+}
+
+// Names lists the registered encoder names, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]string, 0, len(encoders))
+	for name := range encoders {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Encode writes res with the encoder registered under name.
+func Encode(w io.Writer, name string, res ruletrace.TraceResult) error {
+	enc, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("render: unknown encoder %q", name)
+	}
+	return enc.Encode(w, res)
+}
+
+// status is the one-word outcome of a chunk shared by the table and graph encoders.
+func status(c ruletrace.EvalResult) string {
+	switch {
+	case c.Skipped:
+		return "skipped"
+	case c.Unknown:
+		return "unknown"
+	case c.Error != "":
+		return "error"
+	case c.Value == true:
+		return "true"
+	case c.Value == false:
+		return "false"
+	default:
+		return "value"
+	}
+}
+
+func valueString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,72 @@
+package ruletrace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aqilarik/ruletrace/internal/i18n"
+)
+
+// Catalog resolves reason codes to message templates per locale.
+// Templates are rendered like ConditionSpec message templates.
+type Catalog interface {
+	// Lookup returns the template for reason in locale, following the locale's fallback
+	// chain (de-AT -> de -> default). n selects a plural form.
+	Lookup(locale, reason string, n int) (string, bool)
+	// Translated reports whether reason has a message for locale without falling back
+	// to the default locale.
+	Translated(locale, reason string) bool
+	// Locales lists the locales the catalog has messages for.
+	Locales() []string
+}
+
+// ContextCatalog is a Catalog with per-context messages. The tracer looks a reason up
+// with the condition's ID as context first, so one condition can override the shared
+// message of its reason code (a .po msgctxt entry).
+type ContextCatalog interface {
+	Catalog
+	LookupContext(locale, context, reason string, n int) (string, bool)
+}
+
+// MessageCatalog is the built-in in-memory Catalog. It loads JSON documents and
+// gettext .po files, and picks plural forms with per-language rules.
+type MessageCatalog = i18n.Catalog
+
+// NewCatalog creates an empty MessageCatalog; defaultLocale ends every fallback chain.
+func NewCatalog(defaultLocale string) *MessageCatalog { return i18n.New(defaultLocale) }
+
+// ValidateCatalog reports reason codes used in specs that have no translation
+// in some locale of cat.
+func ValidateCatalog(cat Catalog, specs map[string]ConditionSpec) error {
+	reasons := map[string]struct{}{}
+	for _, s := range specs {
+		for _, r := range []string{s.ReasonTrue, s.ReasonFalse, s.ReasonUnknown} {
+			if r != "" {
+				reasons[r] = struct{}{}
+			}
+		}
+	}
+	sorted := make([]string, 0, len(reasons))
+	for r := range reasons {
+		sorted = append(sorted, r)
+	}
+	sort.Strings(sorted)
+
+	var missing []string
+	for _, loc := range cat.Locales() {
+		var codes []string
+		for _, r := range sorted {
+			if !cat.Translated(loc, r) {
+				codes = append(codes, r)
+			}
+		}
+		if len(codes) > 0 {
+			missing = append(missing, loc+": "+strings.Join(codes, ", "))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing translations: %s", strings.Join(missing, "; "))
+	}
+	return nil
+}
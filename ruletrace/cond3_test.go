@@ -0,0 +1,88 @@
+package ruletrace
+
+import (
+	"strings"
+	"testing"
+)
+
+type profile struct{ Age int }
+
+type member struct{ Profile *profile }
+
+func ageSpecs() map[string]ConditionSpec {
+	return map[string]ConditionSpec{
+		Fingerprint(`user.Age >= 18`):         {ID: "c_age", ReasonTrue: "ADULT", ReasonFalse: "MINOR", ReasonUnknown: "AGE_UNKNOWN"},
+		Fingerprint(`user.Age == 18`):         {ID: "c_eq", ReasonTrue: "EIGHTEEN", ReasonFalse: "NOT_EIGHTEEN", ReasonUnknown: "AGE_UNKNOWN"},
+		Fingerprint(`user.Deleted == nil`):    {ID: "c_live", ReasonTrue: "LIVE", ReasonFalse: "DELETED", ReasonUnknown: "LIVE_UNKNOWN"},
+		Fingerprint(`m.Profile.Age >= 18`):    {ID: "c_profile", ReasonTrue: "ADULT", ReasonFalse: "MINOR", ReasonUnknown: "AGE_UNKNOWN"},
+		Fingerprint(`user.Name > 18`):         {ID: "c_type", ReasonTrue: "T", ReasonFalse: "F", ReasonUnknown: "TYPE_UNKNOWN"},
+		Fingerprint(`user.Group == "admin"`):  {ID: "c_group", ReasonTrue: "ADMIN", ReasonFalse: "NOT_ADMIN"},
+		Fingerprint(`user.Profile.Age >= 18`): {ID: "c_nested", ReasonTrue: "ADULT", ReasonFalse: "MINOR", ReasonUnknown: "AGE_UNKNOWN"},
+	}
+}
+
+func TestCond3Unknown(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		env    map[string]interface{}
+		final  interface{}
+		reason string
+		unk    bool
+	}{
+		{"known", `user.Age >= 18`, map[string]interface{}{"user": map[string]interface{}{"Age": 20}}, true, "ADULT", false},
+		{"nil operand", `user.Age >= 18`, map[string]interface{}{"user": map[string]interface{}{"Age": nil}}, false, "AGE_UNKNOWN", true},
+		{"nil operand under ==", `user.Age == 18`, map[string]interface{}{"user": map[string]interface{}{}}, false, "AGE_UNKNOWN", true},
+		{"nil literal is a test", `user.Deleted == nil`, map[string]interface{}{"user": map[string]interface{}{}}, true, "LIVE", false},
+		{"fetch on nil map parent", `user.Profile.Age >= 18`, map[string]interface{}{"user": map[string]interface{}{"Profile": nil}}, false, "AGE_UNKNOWN", true},
+		{"fetch on nil struct pointer", `m.Profile.Age >= 18`, map[string]interface{}{"m": member{}}, false, "AGE_UNKNOWN", true},
+		{"runtime error", `user.Name > 18`, map[string]interface{}{"user": map[string]interface{}{"Name": "bob"}}, false, "TYPE_UNKNOWN", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := New(tt.env).TraceStrict(tt.rule, ageSpecs())
+			if err != nil {
+				t.Fatalf("TraceStrict: %v", err)
+			}
+			if res.Final != tt.final {
+				t.Errorf("Final = %v, want %v", res.Final, tt.final)
+			}
+			c := res.Chunks[0]
+			if c.Reason != tt.reason || c.Unknown != tt.unk {
+				t.Errorf("chunk reason %q unknown %v, want %q %v", c.Reason, c.Unknown, tt.reason, tt.unk)
+			}
+			if c.Error != "" {
+				t.Errorf("chunk error %q", c.Error)
+			}
+			// the operands are nil-safe when compiled, not in the traced source
+			if strings.Contains(c.Expr, "?.") || strings.Contains(res.Source, "?.") {
+				t.Errorf("optional fetches leaked: Expr %q, Source %q", c.Expr, res.Source)
+			}
+		})
+	}
+}
+
+func TestUnknownPolicy(t *testing.T) {
+	env := map[string]interface{}{"user": map[string]interface{}{"Group": "admin"}}
+	rule := `user.Group == "admin" && user.Age >= 18`
+	tests := []struct {
+		policy UnknownPolicy
+		final  interface{}
+	}{
+		{UnknownAsFalse, false},
+		{UnknownAsTrue, true},
+		{UnknownPropagate, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			res := New(env, WithUnknownPolicy(tt.policy)).Trace(rule, ageSpecs())
+			if res.Final != tt.final {
+				t.Errorf("Final = %v, want %v", res.Final, tt.final)
+			}
+			last := res.Chunks[len(res.Chunks)-1]
+			if last.ID != "c_age" || !last.Unknown || last.Reason != "AGE_UNKNOWN" {
+				t.Errorf("age chunk = %+v, want unknown c_age", last)
+			}
+		})
+	}
+}
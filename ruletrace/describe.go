@@ -0,0 +1,246 @@
+package ruletrace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+
+	"github.com/aqilarik/ruletrace/internal/format"
+	"github.com/aqilarik/ruletrace/internal/patch"
+)
+
+// Describe renders a rule as nested bullet points in plain English without evaluating it.
+// Chains of `||` / `&&` become "any of:" / "all of:" bullets with one child per operand,
+// and each atom becomes a phrase such as "user.Group is one of admin, moderator".
+// Atoms matched by specs (or explicit Cond calls) are labelled with the spec ID, or with
+// the spec Description when it has one; other atoms are phrased from their operator.
+// Sub-expressions that are not boolean structure fall back to the formatted expression.
+func Describe(input string, specs map[string]ConditionSpec) (string, error) {
+	root, err := compileNoEnv(input)
+	if err != nil {
+		return "", err
+	}
+	d := &describer{fmter: format.New(), specs: specs, byID: specsByID(specs)}
+	var sb strings.Builder
+	d.node(root, false).write(&sb, 0)
+	return sb.String(), nil
+}
+
+// compileNoEnv compiles input without an env so the AST has the same shape (and atom
+// fingerprints) as the one the tracer patches. Cond/Cond3 are registered as stubs.
+func compileNoEnv(input string) (ast.Node, error) {
+	stub := func(params ...any) (any, error) { return nil, nil }
+	tree, err := expr.Compile(input,
+		expr.Function("Cond", stub),
+		expr.Function("Cond3", stub),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return tree.Node(), nil
+}
+
+type bullet struct {
+	text     string
+	children []bullet
+}
+
+func (b bullet) write(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString("- ")
+	sb.WriteString(b.text)
+	sb.WriteString("\n")
+	for _, c := range b.children {
+		c.write(sb, depth+1)
+	}
+}
+
+type describer struct {
+	fmter *format.Formatter
+	specs map[string]ConditionSpec
+	byID  map[string]ConditionSpec
+}
+
+func (d *describer) node(n ast.Node, neg bool) bullet {
+	switch x := n.(type) {
+	case *ast.ChainNode:
+		return d.node(x.Node, neg)
+
+	case *ast.UnaryNode:
+		if x.Operator == "not" || x.Operator == "!" {
+			return d.node(x.Node, !neg)
+		}
+
+	case *ast.BinaryNode:
+		switch x.Operator {
+		case "||", "or":
+			head := "any of:"
+			if neg {
+				head = "none of:"
+			}
+			return bullet{text: head, children: d.chain(x, "||", "or")}
+		case "&&", "and":
+			head := "all of:"
+			if neg {
+				head = "not all of:"
+			}
+			return bullet{text: head, children: d.chain(x, "&&", "and")}
+		case "??":
+			return bullet{text: negate("the first that is not nil of:", neg), children: []bullet{
+				d.node(x.Left, false),
+				d.node(x.Right, false),
+			}}
+		}
+		if patch.IsAtomNode(x) {
+			return bullet{text: d.label(x, d.atom(x.Operator, x.Left, x.Right, neg))}
+		}
+
+	case *ast.ConditionalNode:
+		return bullet{text: negate("depending on a condition:", neg), children: []bullet{
+			{text: "if:", children: []bullet{d.node(x.Cond, false)}},
+			{text: "then:", children: []bullet{d.node(x.Exp1, false)}},
+			{text: "otherwise:", children: []bullet{d.node(x.Exp2, false)}},
+		}}
+
+	case *ast.CallNode:
+		if patch.IsCondCall(x) {
+			return d.cond(x, neg)
+		}
+		if name, ok := patch.RuleCallName(x); ok {
+			if neg {
+				return bullet{text: "rule " + name + " does not pass"}
+			}
+			return bullet{text: "rule " + name + " passes"}
+		}
+
+	case *ast.BoolNode:
+		return bullet{text: fmt.Sprintf("always %v", x.Value != neg)}
+	}
+
+	return bullet{text: negate(d.fmter.Format(n), neg)}
+}
+
+// chain flattens a chain of the same boolean operator into sibling bullets.
+func (d *describer) chain(n ast.Node, ops ...string) []bullet {
+	if ch, ok := n.(*ast.ChainNode); ok {
+		n = ch.Node
+	}
+	if bn, ok := n.(*ast.BinaryNode); ok && (bn.Operator == ops[0] || bn.Operator == ops[1]) {
+		return append(d.chain(bn.Left, ops...), d.chain(bn.Right, ops...)...)
+	}
+	return []bullet{d.node(n, false)}
+}
+
+// cond describes an explicit or patched Cond/Cond3 call by its ID and predicate.
+func (d *describer) cond(call *ast.CallNode, neg bool) bullet {
+	id := ""
+	if sn, ok := call.Arguments[0].(*ast.StringNode); ok {
+		id = sn.Value
+	}
+	text := ""
+	if op, left, right, ok := patch.CondOperands(call); ok {
+		text = d.atom(op, left, right, neg)
+	} else {
+		b := d.node(call.Arguments[len(call.Arguments)-1], neg)
+		if len(b.children) > 0 {
+			b.text = d.withSpec(id, b.text)
+			return b
+		}
+		text = b.text
+	}
+	return bullet{text: d.withSpec(id, text)}
+}
+
+// label prefixes an atom phrase with its spec, looked up by fingerprint.
+func (d *describer) label(n ast.Node, text string) string {
+	spec, ok := d.specs[Fingerprint(d.fmter.Format(n))]
+	if !ok {
+		return text
+	}
+	return d.withSpec(spec.ID, text)
+}
+
+func (d *describer) withSpec(id, text string) string {
+	if spec, ok := d.byID[id]; ok && spec.Description != "" {
+		return spec.Description + " (" + id + ")"
+	}
+	if id != "" {
+		return id + ": " + text
+	}
+	return text
+}
+
+var atomPhrases = map[string][2]string{
+	"==":         {"equals", "does not equal"},
+	"!=":         {"does not equal", "equals"},
+	"<":          {"is less than", "is at least"},
+	"<=":         {"is at most", "is greater than"},
+	">":          {"is greater than", "is at most"},
+	">=":         {"is at least", "is less than"},
+	"in":         {"is one of", "is not one of"},
+	"matches":    {"matches", "does not match"},
+	"contains":   {"contains", "does not contain"},
+	"startsWith": {"starts with", "does not start with"},
+	"endsWith":   {"ends with", "does not end with"},
+}
+
+func (d *describer) atom(op string, left, right ast.Node, neg bool) string {
+	phrase, ok := atomPhrases[op]
+	if !ok {
+		return negate(fmt.Sprintf("%s %s %s", d.fmter.Format(left), op, d.fmter.Format(right)), neg)
+	}
+	verb := phrase[0]
+	if neg {
+		verb = phrase[1]
+	}
+	rhs := d.fmter.Format(right)
+	if op == "in" {
+		rhs = d.list(right)
+	}
+	return fmt.Sprintf("%s %s %s", d.fmter.Format(left), verb, rhs)
+}
+
+// list renders a literal collection as "a, b, c"; anything else is formatted as-is.
+func (d *describer) list(n ast.Node) string {
+	var items []string
+	switch x := n.(type) {
+	case *ast.ArrayNode:
+		for _, el := range x.Nodes {
+			items = append(items, plainLiteral(d.fmter, el))
+		}
+	case *ast.ConstantNode:
+		switch v := x.Value.(type) {
+		case map[string]struct{}:
+			for k := range v {
+				items = append(items, k)
+			}
+			sort.Strings(items)
+		case []interface{}:
+			for _, el := range v {
+				items = append(items, fmt.Sprintf("%v", el))
+			}
+		default:
+			return d.fmter.Format(n)
+		}
+	default:
+		return d.fmter.Format(n)
+	}
+	return strings.Join(items, ", ")
+}
+
+func plainLiteral(fmter *format.Formatter, n ast.Node) string {
+	if sn, ok := n.(*ast.StringNode); ok {
+		return sn.Value
+	}
+	return fmter.Format(n)
+}
+
+func negate(text string, neg bool) string {
+	if neg {
+		return "not: " + text
+	}
+	return text
+}
@@ -0,0 +1,50 @@
+package ruletrace
+
+import "testing"
+
+func TestDescribe(t *testing.T) {
+	specs := map[string]ConditionSpec{
+		Fingerprint(`user.Group in ["admin", "moderator"]`): {ID: "c_group"},
+		Fingerprint(`user.Id == comment.UserId`):            {ID: "c_owner", Description: "User owns the comment"},
+	}
+	tests := []struct {
+		name, rule, want string
+	}{
+		{"spec IDs and descriptions", `user.Group in ["admin", "moderator"] || user.Id == comment.UserId`,
+			"- any of:\n  - c_group: user.Group is one of admin, moderator\n  - User owns the comment (c_owner)\n"},
+		{"plain atom", `user.Age >= 18`, "- user.Age is at least 18\n"},
+		{"negated atom", `!(user.Age >= 18)`, "- user.Age is less than 18\n"},
+		{"flattened chain", `a > 1 && b > 2 && c > 3`,
+			"- all of:\n  - a is greater than 1\n  - b is greater than 2\n  - c is greater than 3\n"},
+		{"nested chains", `a > 1 || (b > 2 && c > 3)`,
+			"- any of:\n  - a is greater than 1\n  - all of:\n    - b is greater than 2\n    - c is greater than 3\n"},
+		{"negated chains", `not (a > 1 || b > 2)`, "- none of:\n  - a is greater than 1\n  - b is greater than 2\n"},
+		{"negated and", `not (a > 1 && b > 2)`, "- not all of:\n  - a is greater than 1\n  - b is greater than 2\n"},
+		{"string ops", `name startsWith "a" && name contains "b"`,
+			"- all of:\n  - name starts with \"a\"\n  - name contains \"b\"\n"},
+		{"authored Cond", `Cond("c_age", "ADULT", "MINOR", user.Age >= 18)`, "- c_age: user.Age is at least 18\n"},
+		{"rule reference", `Rule("adult") && !Rule("banned")`,
+			"- all of:\n  - rule adult passes\n  - rule banned does not pass\n"},
+		{"conditional", `a ? b > 1 : c`,
+			"- depending on a condition:\n  - if:\n    - a\n  - then:\n    - b is greater than 1\n  - otherwise:\n    - c\n"},
+		{"constant", `true`, "- always true\n"},
+		{"other expression", `len(tags) > 0`, "- len(tags) is greater than 0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Describe(tt.rule, specs)
+			if err != nil {
+				t.Fatalf("Describe: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Describe =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeError(t *testing.T) {
+	if _, err := Describe(`a >`, nil); err == nil {
+		t.Fatal("Describe of an invalid rule: want an error")
+	}
+}
@@ -0,0 +1,198 @@
+package ruletrace
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind classifies how a chunk differs between two traces.
+type ChangeKind uint8
+
+const (
+	ChangeValue     ChangeKind = iota // evaluated in both, outcome differs
+	ChangeReason                      // same outcome, different reason code
+	ChangeEvaluated                   // skipped before, evaluated now
+	ChangeSkipped                     // evaluated before, skipped now
+	ChangeAdded                       // condition only in the newer trace
+	ChangeRemoved                     // condition only in the older trace
+)
+
+func (k ChangeKind) String() string {
+	return [...]string{"value", "reason", "evaluated", "skipped", "added", "removed"}[k]
+}
+
+// TraceDiff compares two traces of the same decision.
+type TraceDiff struct {
+	FinalBefore interface{}
+	FinalAfter  interface{}
+	Changes     []ChunkChange // chunks that differ, in order of the newer trace, then removed ones
+}
+
+// ChunkChange pairs a chunk across two traces. Before or After is nil when the
+// chunk exists on one side only.
+type ChunkChange struct {
+	Key    string // spec ID, else fingerprint (with "#n" for repeats)
+	Kind   ChangeKind
+	Before *EvalResult
+	After  *EvalResult
+}
+
+// FinalChanged reports whether the decision flipped.
+func (d TraceDiff) FinalChanged() bool {
+	return !reflect.DeepEqual(d.FinalBefore, d.FinalAfter)
+}
+
+// Changed reports whether anything differs.
+func (d TraceDiff) Changed() bool {
+	return d.FinalChanged() || len(d.Changes) > 0
+}
+
+// String renders the diff for humans, one line per change:
+//
+//	final: true -> false
+//	  value      c_owner: true (IS_OWNER) -> false (NOT_OWNER)
+//	  evaluated  c_name: skipped -> false (NAME_MISMATCH)
+//	  removed    c_group: true (GROUP_ALLOWED)
+func (d TraceDiff) String() string {
+	var sb strings.Builder
+	if d.FinalChanged() {
+		fmt.Fprintf(&sb, "final: %v -> %v\n", d.FinalBefore, d.FinalAfter)
+	} else {
+		fmt.Fprintf(&sb, "final: %v (unchanged)\n", d.FinalAfter)
+	}
+	for _, c := range d.Changes {
+		fmt.Fprintf(&sb, "  %-10s %s: ", c.Kind, c.label())
+		switch {
+		case c.Before == nil:
+			sb.WriteString(describeOutcome(*c.After))
+		case c.After == nil:
+			sb.WriteString(describeOutcome(*c.Before))
+		default:
+			sb.WriteString(describeOutcome(*c.Before) + " -> " + describeOutcome(*c.After))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (c ChunkChange) label() string {
+	r := c.After
+	if r == nil {
+		r = c.Before
+	}
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.Expr
+}
+
+func describeOutcome(r EvalResult) string {
+	var s string
+	switch {
+	case r.Skipped:
+		return "skipped"
+	case r.Unknown:
+		s = "unknown"
+	case r.Error != "":
+		msg, _, _ := strings.Cut(r.Error, "\n")
+		return "error: " + msg
+	default:
+		s = fmt.Sprintf("%v", r.Value)
+	}
+	if r.Reason != "" {
+		s += " (" + r.Reason + ")"
+	}
+	return s
+}
+
+// Diff compares two traces, e.g. of the same rule before and after an edit, or of one
+// rule against two envs. Chunks are aligned by Cond ID, falling back to Fingerprint, so
+// the same condition is matched across rule versions; only differing chunks are kept.
+func Diff(a, b TraceResult) TraceDiff {
+	d := TraceDiff{FinalBefore: a.Final, FinalAfter: b.Final}
+
+	before := keyChunks(a.Chunks)
+	seen := map[string]bool{}
+	for _, kc := range keyChunks(b.Chunks) {
+		seen[kc.key] = true
+		old, ok := lookupKey(before, kc.key)
+		if !ok {
+			d.Changes = append(d.Changes, ChunkChange{Key: kc.key, Kind: ChangeAdded, After: kc.chunk})
+			continue
+		}
+		if kind, changed := compareChunks(*old, *kc.chunk); changed {
+			d.Changes = append(d.Changes, ChunkChange{Key: kc.key, Kind: kind, Before: old, After: kc.chunk})
+		}
+	}
+	for _, kc := range before {
+		if !seen[kc.key] {
+			d.Changes = append(d.Changes, ChunkChange{Key: kc.key, Kind: ChangeRemoved, Before: kc.chunk})
+		}
+	}
+	return d
+}
+
+type keyedChunk struct {
+	key   string
+	chunk *EvalResult
+}
+
+// keyChunks keys chunks for alignment. A skipped subtree holding several Cond calls is
+// split into one skipped entry per ID, so each condition aligns with its evaluated
+// counterpart in the other trace.
+func keyChunks(chunks []EvalResult) []keyedChunk {
+	out := make([]keyedChunk, 0, len(chunks))
+	count := map[string]int{}
+	add := func(key string, c *EvalResult) {
+		count[key]++
+		if n := count[key]; n > 1 {
+			key += "#" + strconv.Itoa(n)
+		}
+		out = append(out, keyedChunk{key: key, chunk: c})
+	}
+	for i := range chunks {
+		c := &chunks[i]
+		if c.ID != "" {
+			add(c.ID, c)
+			continue
+		}
+		if ids := condIDs(c.Expr); c.Skipped && len(ids) > 0 {
+			for _, id := range ids {
+				add(id, &EvalResult{ID: id, Expr: c.Expr, Fingerprint: c.Fingerprint, Skipped: true})
+			}
+			continue
+		}
+		add(c.Fingerprint, c)
+	}
+	return out
+}
+
+func lookupKey(kcs []keyedChunk, key string) (*EvalResult, bool) {
+	for _, kc := range kcs {
+		if kc.key == key {
+			return kc.chunk, true
+		}
+	}
+	return nil, false
+}
+
+// compareChunks classifies the difference between two aligned chunks. Error texts are
+// not compared: they name Go types, which differ between a live env and its replayed
+// snapshot.
+func compareChunks(a, b EvalResult) (ChangeKind, bool) {
+	switch {
+	case a.Skipped && b.Skipped:
+		return 0, false
+	case a.Skipped:
+		return ChangeEvaluated, true
+	case b.Skipped:
+		return ChangeSkipped, true
+	case !reflect.DeepEqual(a.Value, b.Value) || a.Unknown != b.Unknown || (a.Error == "") != (b.Error == ""):
+		return ChangeValue, true
+	case a.Reason != b.Reason:
+		return ChangeReason, true
+	}
+	return 0, false
+}
@@ -0,0 +1,117 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func ownerSpecs() map[string]ConditionSpec {
+	return map[string]ConditionSpec{
+		Fingerprint(`user.Group in ["admin", "moderator"]`): {ID: "c_group", ReasonTrue: "GROUP_ALLOWED", ReasonFalse: "GROUP_NOT_ALLOWED"},
+		Fingerprint(`user.Id == comment.UserId`):            {ID: "c_owner", ReasonTrue: "IS_OWNER", ReasonFalse: "NOT_OWNER"},
+		Fingerprint(`user.Name == "bob"`):                   {ID: "c_name", ReasonTrue: "NAME_MATCH", ReasonFalse: "NAME_MISMATCH"},
+	}
+}
+
+func ownerEnv(group string, id int) map[string]interface{} {
+	return map[string]interface{}{
+		"user":    map[string]interface{}{"Group": group, "Id": id, "Name": "bob"},
+		"comment": map[string]interface{}{"UserId": 1},
+	}
+}
+
+type change struct {
+	key  string
+	kind ChangeKind
+}
+
+func TestDiff(t *testing.T) {
+	const rule = `user.Group in ["admin", "moderator"] || user.Id == comment.UserId`
+	tests := []struct {
+		name         string
+		ruleA, ruleB string
+		envA, envB   map[string]interface{}
+		finalChanged bool
+		changes      []change
+	}{
+		{"identical", rule, rule, ownerEnv("admin", 1), ownerEnv("admin", 1), false, nil},
+		{"skipped subtree now evaluated", rule, rule, ownerEnv("admin", 2), ownerEnv("user", 2), true,
+			[]change{{"c_group", ChangeValue}, {"c_owner", ChangeEvaluated}}},
+		{"evaluated now skipped", rule, rule, ownerEnv("user", 1), ownerEnv("admin", 1), false,
+			[]change{{"c_group", ChangeValue}, {"c_owner", ChangeSkipped}}},
+		{"condition added and removed", rule, `user.Group in ["admin", "moderator"] || user.Name == "bob"`,
+			ownerEnv("user", 1), ownerEnv("user", 1), false,
+			[]change{{"c_name", ChangeAdded}, {"c_owner", ChangeRemoved}}},
+		{"reordered rule aligns by ID", rule, `user.Id == comment.UserId || user.Group in ["admin", "moderator"]`,
+			ownerEnv("admin", 1), ownerEnv("admin", 1), false,
+			[]change{{"c_owner", ChangeEvaluated}, {"c_group", ChangeSkipped}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(tt.envA, WithMode(TraceAtomic)).Trace(tt.ruleA, ownerSpecs())
+			b := New(tt.envB, WithMode(TraceAtomic)).Trace(tt.ruleB, ownerSpecs())
+			d := Diff(a, b)
+			if d.FinalChanged() != tt.finalChanged {
+				t.Errorf("FinalChanged = %v, want %v", d.FinalChanged(), tt.finalChanged)
+			}
+			var got []change
+			for _, c := range d.Changes {
+				got = append(got, change{c.Key, c.Kind})
+			}
+			if !reflect.DeepEqual(got, tt.changes) {
+				t.Fatalf("changes = %v, want %v\n%s", got, tt.changes, d)
+			}
+			if d.Changed() != (tt.finalChanged || len(tt.changes) > 0) {
+				t.Errorf("Changed = %v", d.Changed())
+			}
+		})
+	}
+}
+
+func TestDiffChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []EvalResult
+		after  []EvalResult
+		want   []change
+	}{
+		{"reason only", []EvalResult{{ID: "c", Value: true, Reason: "A"}}, []EvalResult{{ID: "c", Value: true, Reason: "B"}},
+			[]change{{"c", ChangeReason}}},
+		{"unknown", []EvalResult{{ID: "c", Value: false}}, []EvalResult{{ID: "c", Value: false, Unknown: true}},
+			[]change{{"c", ChangeValue}}},
+		{"error texts are not compared", []EvalResult{{ID: "c", Error: "int > string"}}, []EvalResult{{ID: "c", Error: "float64 > string"}}, nil},
+		{"error appears", []EvalResult{{ID: "c", Value: true}}, []EvalResult{{ID: "c", Error: "boom"}},
+			[]change{{"c", ChangeValue}}},
+		{"repeated fingerprints", []EvalResult{{Fingerprint: "f", Value: true}, {Fingerprint: "f", Value: true}},
+			[]EvalResult{{Fingerprint: "f", Value: true}, {Fingerprint: "f", Value: false}},
+			[]change{{"f#2", ChangeValue}}},
+		{"skipped subtree split by ID",
+			[]EvalResult{{ID: "a", Value: true}, {Expr: `Cond("b", "B", "NB", x > 1) && Cond("c", "C", "NC", y > 1)`, Skipped: true}},
+			[]EvalResult{{ID: "a", Value: false}, {ID: "b", Value: true}, {ID: "c", Skipped: true}},
+			[]change{{"a", ChangeValue}, {"b", ChangeEvaluated}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Diff(TraceResult{Chunks: tt.before}, TraceResult{Chunks: tt.after})
+			var got []change
+			for _, c := range d.Changes {
+				got = append(got, change{c.Key, c.Kind})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("changes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffString(t *testing.T) {
+	const rule = `user.Group in ["admin", "moderator"] || user.Id == comment.UserId`
+	a := New(ownerEnv("admin", 2), WithMode(TraceAtomic)).Trace(rule, ownerSpecs())
+	b := New(ownerEnv("user", 2), WithMode(TraceAtomic)).Trace(rule, ownerSpecs())
+	want := "final: true -> false\n" +
+		"  value      c_group: true (GROUP_ALLOWED) -> false (GROUP_NOT_ALLOWED)\n" +
+		"  evaluated  c_owner: skipped -> false (NOT_OWNER)\n"
+	if got := Diff(a, b).String(); got != want {
+		t.Fatalf("String =\n%s\nwant\n%s", got, want)
+	}
+}
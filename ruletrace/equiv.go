@@ -0,0 +1,258 @@
+package ruletrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/access"
+)
+
+// Equivalence is the outcome of comparing two versions of a rule.
+type Equivalence struct {
+	Before      string
+	After       string
+	Equivalent  bool                   // no env telling them apart was found
+	Complete    bool                   // Equivalent is proved, not just unrefuted (see Solve)
+	Witness     map[string]interface{} // env on which they differ, when not Equivalent
+	FinalBefore interface{}            // Final of Before on Witness
+	FinalAfter  interface{}            // Final of After on Witness
+	CorpusIndex int                    // index of Witness in the corpus, -1 when solved for
+	Sampled     int                    // corpus envs evaluated
+}
+
+// Compare looks for an env on which before and after give different Finals. The corpus
+// (stored envs, see CorpusFromTraces) is tried first, in order; then assignments are
+// searched as in Solve, over the candidates of both rules. Paths a corpus env lacks
+// evaluate to nil. opts apply to evaluating both rules.
+func Compare(before, after string, corpus []map[string]interface{}, opts ...Option) (Equivalence, error) {
+	out := Equivalence{Before: before, After: after, CorpusIndex: -1}
+	t := New(nil, opts...)
+	roots, err := ruleRoots([]string{before, after}, t.rules)
+	if err != nil {
+		return Equivalence{}, fmt.Errorf("compare: %w", err)
+	}
+
+	sampleOpts := append(opts[:len(opts):len(opts)], allowUndefined())
+	for i, env := range corpus {
+		out.Sampled++
+		st := New(env, sampleOpts...)
+		a, b := st.Trace(before, nil).Final, st.Trace(after, nil).Final
+		if !reflect.DeepEqual(a, b) {
+			out.Witness, out.FinalBefore, out.FinalAfter, out.CorpusIndex = env, a, b, i
+			return out, nil
+		}
+	}
+
+	vars, exact := t.candidates(roots...)
+	s := &solver{vars: vars, exact: exact, check: func(env map[string]interface{}, unknown []string) (bool, interface{}) {
+		a, errA := Partial(before, nil, env, unknown, opts...)
+		b, errB := Partial(after, nil, env, unknown, opts...)
+		if errA != nil || errB != nil || !a.Decided || !b.Decided {
+			return false, nil
+		}
+		return true, !reflect.DeepEqual(a.Final, b.Final)
+	}}
+	w := s.witness(true)
+	if !w.found {
+		out.Equivalent, out.Complete = true, w.definitive()
+		return out, nil
+	}
+	wt := New(w.env, opts...)
+	out.Witness, out.FinalBefore, out.FinalAfter = w.env, wt.Trace(before, nil).Final, wt.Trace(after, nil).Final
+	return out, nil
+}
+
+// String renders the comparison for a review:
+//
+//	not equivalent: true before, false after
+//	  env (solved): {"user":{"Age":17}}
+func (e Equivalence) String() string {
+	if e.Equivalent {
+		if e.Complete {
+			return "equivalent"
+		}
+		return fmt.Sprintf("no difference found (bounded search, %d corpus envs)", e.Sampled)
+	}
+	return fmt.Sprintf("not equivalent: %v before, %v after\n  env (%s): %s",
+		e.FinalBefore, e.FinalAfter, witnessSource(e.CorpusIndex), envJSON(e.Witness))
+}
+
+// Conflict is a permit rule and a deny rule of a RuleSet that match together.
+type Conflict struct {
+	Permit      string
+	Deny        string
+	Witness     map[string]interface{} // env on which both are true
+	CorpusIndex int                    // index of Witness in the corpus, -1 when solved for
+}
+
+// ConflictReport lists the conflicting rule pairs of a RuleSet.
+type ConflictReport struct {
+	Combining Combining
+	Conflicts []Conflict // in rule order, permit rule first
+	Complete  bool       // the pairs not listed are proved never to match together
+	Sampled   int        // corpus envs evaluated
+}
+
+// Conflicts looks for pairs of a permit rule and a deny rule of rs that are both true
+// on some env, whatever the combining algorithm makes of it. Each pair is tried on the
+// corpus first, then searched for as in Solve, over the candidates of both rules and of
+// the rules they reference. opts apply to evaluating the rules.
+func Conflicts(rs *RuleSet, corpus []map[string]interface{}, opts ...Option) (ConflictReport, error) {
+	opts = append([]Option{WithRules(rs.registry)}, opts...)
+	out := ConflictReport{Combining: rs.combining, Complete: true, Sampled: len(corpus)}
+
+	// matched[i][name]: the rule is true on corpus env i.
+	matched := make([]map[string]bool, len(corpus))
+	sampleOpts := append(opts[:len(opts):len(opts)], allowUndefined())
+	for i, env := range corpus {
+		s := NewSession(env, sampleOpts...)
+		matched[i] = map[string]bool{}
+		for _, r := range rs.rules {
+			matched[i][r.Name] = s.Trace(r.Expr, nil).Final == true
+		}
+	}
+
+	for _, p := range rs.rules {
+		if p.Effect != EffectPermit {
+			continue
+		}
+		for _, d := range rs.rules {
+			if d.Effect != EffectDeny {
+				continue
+			}
+			c, found, definitive, err := conflict(p, d, corpus, matched, rs.registry, opts)
+			if err != nil {
+				return ConflictReport{}, fmt.Errorf("conflicts: %w", err)
+			}
+			if found {
+				out.Conflicts = append(out.Conflicts, c)
+			}
+			out.Complete = out.Complete && definitive
+		}
+	}
+	return out, nil
+}
+
+// conflict looks for an env on which both p and d are true.
+func conflict(p, d Rule, corpus []map[string]interface{}, matched []map[string]bool, reg *Registry, opts []Option) (c Conflict, found, definitive bool, err error) {
+	c = Conflict{Permit: p.Name, Deny: d.Name, CorpusIndex: -1}
+	for i := range corpus {
+		if matched[i][p.Name] && matched[i][d.Name] {
+			c.Witness, c.CorpusIndex = corpus[i], i
+			return c, true, true, nil
+		}
+	}
+
+	roots, err := ruleRoots([]string{p.Expr, d.Expr}, reg)
+	if err != nil {
+		return c, false, false, err
+	}
+	vars, exact := New(nil, opts...).candidates(roots...)
+	s := &solver{vars: vars, exact: exact, check: func(env map[string]interface{}, unknown []string) (bool, interface{}) {
+		both := true
+		for _, src := range []string{p.Expr, d.Expr} {
+			r, err := Partial(src, nil, env, unknown, opts...)
+			switch {
+			case err != nil:
+				return true, false
+			case !r.Decided:
+				both = false
+			case r.Final != true:
+				return true, false // this one cannot match, whatever the other does
+			}
+		}
+		return both, both
+	}}
+	w := s.witness(true)
+	c.Witness = w.env
+	return c, w.found, w.definitive(), nil
+}
+
+// String renders the report for a review:
+//
+//	deny-overrides: 1 conflict
+//	  permit "staff" and deny "banned" both match (solved): {"user":{"Banned":true,"Staff":true}}
+func (r ConflictReport) String() string {
+	var b strings.Builder
+	switch {
+	case len(r.Conflicts) == 1:
+		fmt.Fprintf(&b, "%s: 1 conflict", r.Combining)
+	case len(r.Conflicts) > 1:
+		fmt.Fprintf(&b, "%s: %d conflicts", r.Combining, len(r.Conflicts))
+	case r.Complete:
+		fmt.Fprintf(&b, "%s: no conflicts", r.Combining)
+	default:
+		fmt.Fprintf(&b, "%s: no conflicts found (bounded search, %d corpus envs)", r.Combining, r.Sampled)
+	}
+	for _, c := range r.Conflicts {
+		fmt.Fprintf(&b, "\n  permit %q and deny %q both match (%s): %s",
+			c.Permit, c.Deny, witnessSource(c.CorpusIndex), envJSON(c.Witness))
+	}
+	return b.String()
+}
+
+// CorpusFromTraces rebuilds the envs of stored traces from their Inputs, skipping
+// traces recorded without them.
+func CorpusFromTraces(traces []TraceResult) ([]map[string]interface{}, error) {
+	var out []map[string]interface{}
+	for i, tr := range traces {
+		if tr.Inputs == nil {
+			continue
+		}
+		env, err := access.Expand(tr.Inputs)
+		if err != nil {
+			return nil, fmt.Errorf("corpus: trace %d: %w", i, err)
+		}
+		out = append(out, env)
+	}
+	return out, nil
+}
+
+// ruleRoots parses srcs and the rules they reference through reg, transitively.
+func ruleRoots(srcs []string, reg *Registry) ([]ast.Node, error) {
+	var roots []ast.Node
+	seen := map[string]bool{}
+	for len(srcs) > 0 {
+		src := srcs[0]
+		srcs = srcs[1:]
+		tree, err := parser.Parse(src)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, tree.Node)
+		if reg == nil {
+			continue
+		}
+		names, err := ruleRefs(src)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if r, ok := reg.Lookup(name); ok && !seen[name] {
+				seen[name] = true
+				srcs = append(srcs, r.Expr)
+			}
+		}
+	}
+	return roots, nil
+}
+
+func witnessSource(corpusIndex int) string {
+	if corpusIndex < 0 {
+		return "solved"
+	}
+	return fmt.Sprintf("corpus #%d", corpusIndex)
+}
+
+func envJSON(env map[string]interface{}) string {
+	b, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Sprint(env)
+	}
+	return string(b)
+}
@@ -0,0 +1,93 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name       string
+		before     string
+		after      string
+		equivalent bool
+		complete   bool
+	}{
+		{"reordered", `x > 1 && y`, `y && x > 1`, true, true},
+		{"bound moved", `x > 1`, `x >= 1`, false, false},
+		{"negated bound", `!(x > 1)`, `x <= 1`, true, true},
+		{"contains split", `name contains "ab"`, `name contains "a" && name contains "b"`, false, false},
+		{"startsWith twice", `name startsWith "ab"`, `name startsWith "ab" && name startsWith "a"`, true, false},
+		{"string order", `name > "m"`, `name >= "m"`, false, false},
+		{"nil test", `x == nil`, `false`, false, false},
+		{"nil test always", `x == nil`, `true`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eq, err := Compare(tt.before, tt.after, nil)
+			if err != nil {
+				t.Fatalf("Compare: %v", err)
+			}
+			if eq.Equivalent != tt.equivalent || eq.Complete != tt.complete {
+				t.Fatalf("Equivalent %v Complete %v, want %v %v", eq.Equivalent, eq.Complete, tt.equivalent, tt.complete)
+			}
+			if eq.Equivalent {
+				return
+			}
+			if reflect.DeepEqual(eq.FinalBefore, eq.FinalAfter) {
+				t.Fatalf("witness %v gives %v for both", eq.Witness, eq.FinalBefore)
+			}
+		})
+	}
+}
+
+func TestCompareCorpus(t *testing.T) {
+	corpus := []map[string]interface{}{{"x": 2}, {"x": 1}}
+	eq, err := Compare(`x > 1`, `x >= 1`, corpus)
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if eq.Equivalent || eq.CorpusIndex != 1 || eq.Sampled != 2 {
+		t.Fatalf("Equivalent %v CorpusIndex %d Sampled %d, want false 1 2", eq.Equivalent, eq.CorpusIndex, eq.Sampled)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		name      string
+		permit    string
+		deny      string
+		conflicts int
+		complete  bool
+	}{
+		{"disjoint", `x > 1`, `x < 1`, 0, true},
+		{"overlap", `x > 1`, `x > 2`, 1, true},
+		{"contains", `name contains "ab"`, `name contains "b"`, 1, true},
+		{"startsWith clash", `name startsWith "ab"`, `name startsWith "b"`, 0, false},
+		{"pattern", `name matches "^a+$"`, `name contains "b"`, 0, false},
+		{"not nil", `user.Name != nil`, `user.Age > 18`, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := NewRuleSet(CombineDenyOverrides,
+				Rule{Name: "p", Expr: tt.permit, Effect: EffectPermit},
+				Rule{Name: "d", Expr: tt.deny, Effect: EffectDeny})
+			if err != nil {
+				t.Fatalf("NewRuleSet: %v", err)
+			}
+			rep, err := Conflicts(rs, nil)
+			if err != nil {
+				t.Fatalf("Conflicts: %v", err)
+			}
+			if len(rep.Conflicts) != tt.conflicts || rep.Complete != tt.complete {
+				t.Fatalf("%d conflicts Complete %v, want %d %v\n%s", len(rep.Conflicts), rep.Complete, tt.conflicts, tt.complete, rep)
+			}
+			for _, c := range rep.Conflicts {
+				res := rs.Evaluate(c.Witness, allowUndefined())
+				if !res.Rules[0].Matched || !res.Rules[1].Matched {
+					t.Fatalf("witness %v does not match both rules", c.Witness)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package ruletrace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/builtin"
+	"github.com/expr-lang/expr/parser"
+	"github.com/expr-lang/expr/parser/utils"
+
+	"github.com/aqilarik/ruletrace/internal/access"
+	"github.com/aqilarik/ruletrace/internal/eval"
+	"github.com/aqilarik/ruletrace/internal/format"
+)
+
+// NamedCondition is a reusable boolean expression registered in a Library.
+//
+// Rules reference it by name, as an identifier (`is_staff`) when it has no Params or as
+// a call (`is_staff(user)`) binding Params to the arguments. Spec describes the
+// reference as a whole (its ID defaults to Name); Specs are matched against the atoms
+// of Expr, as for any rule.
+type NamedCondition struct {
+	Name   string
+	Params []string
+	Expr   string
+	Spec   ConditionSpec
+	Specs  map[string]ConditionSpec
+}
+
+// Library holds named conditions shared across rules. Rules resolve references when they
+// are traced, so editing an entry with Set updates every rule that uses it.
+type Library struct {
+	mu    sync.RWMutex
+	conds map[string]NamedCondition
+}
+
+// NewLibrary validates and registers conds (see Set).
+func NewLibrary(conds ...NamedCondition) (*Library, error) {
+	l := &Library{conds: map[string]NamedCondition{}}
+	for _, c := range conds {
+		if err := l.Set(c); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Set adds or replaces a condition. The name must be a valid identifier that is not a
+// builtin or Cond/Cond3, Expr must parse, and references between conditions must not
+// form a cycle.
+func (l *Library) Set(c NamedCondition) error {
+	if !utils.IsValidIdentifier(c.Name) || c.Name == "Cond" || c.Name == "Cond3" {
+		return fmt.Errorf("library: invalid condition name %q", c.Name)
+	}
+	if _, ok := builtin.Index[c.Name]; ok {
+		return fmt.Errorf("library: condition name %q is a builtin", c.Name)
+	}
+	seen := map[string]bool{}
+	for _, p := range c.Params {
+		if !utils.IsValidIdentifier(p) || seen[p] {
+			return fmt.Errorf("library: condition %q: invalid or duplicate param %q", c.Name, p)
+		}
+		seen[p] = true
+	}
+	if _, err := parser.Parse(c.Expr); err != nil {
+		return fmt.Errorf("library: condition %q: %w", c.Name, err)
+	}
+	if err := ValidateSpecs(c.Specs); err != nil {
+		return fmt.Errorf("library: condition %q: %w", c.Name, err)
+	}
+	if c.Spec.ID == "" {
+		c.Spec.ID = c.Name
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev, had := l.conds[c.Name]
+	l.conds[c.Name] = c
+	if cycle := l.cycle(); cycle != nil {
+		if had {
+			l.conds[c.Name] = prev
+		} else {
+			delete(l.conds, c.Name)
+		}
+		return fmt.Errorf("library: reference cycle %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// Lookup returns the condition registered under name.
+func (l *Library) Lookup(name string) (NamedCondition, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	c, ok := l.conds[name]
+	return c, ok
+}
+
+// Names lists the registered conditions, sorted.
+func (l *Library) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	names := make([]string, 0, len(l.conds))
+	for n := range l.conds {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (l *Library) snapshot() map[string]NamedCondition {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make(map[string]NamedCondition, len(l.conds))
+	for n, c := range l.conds {
+		out[n] = c
+	}
+	return out
+}
+
+// cycle returns a reference cycle between conditions, or nil. Callers hold l.mu.
+func (l *Library) cycle() []string {
+	refs := map[string][]string{}
+	for name, c := range l.conds {
+		refs[name] = referencedNames(c.Expr, func(n string) bool { _, ok := l.conds[n]; return ok })
+	}
+	return findCycle(refs)
+}
+
+// referencedNames returns the names in src, as identifiers or callees, for which isRef
+// is true. Names are deduplicated and sorted.
+func referencedNames(src string, isRef func(string) bool) []string {
+	tree, err := parser.Parse(src)
+	if err != nil {
+		return nil
+	}
+	set := map[string]bool{}
+	ast.Find(tree.Node, func(n ast.Node) bool {
+		if id, ok := n.(*ast.IdentifierNode); ok && isRef(id.Value) {
+			set[id.Value] = true
+		}
+		return false
+	})
+	out := make([]string, 0, len(set))
+	for n := range set {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// findCycle runs a depth-first search over refs and returns the first cycle found,
+// starting and ending with the same name.
+func findCycle(refs map[string][]string) []string {
+	names := make([]string, 0, len(refs))
+	for n := range refs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(string) []string
+	visit = func(n string) []string {
+		state[n] = active
+		stack = append(stack, n)
+		for _, m := range refs[n] {
+			switch state[m] {
+			case active:
+				for i, s := range stack {
+					if s == m {
+						return append(append([]string(nil), stack[i:]...), m)
+					}
+				}
+			case unvisited:
+				if c := visit(m); c != nil {
+					return c
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = done
+		return nil
+	}
+	for _, n := range names {
+		if state[n] == unvisited {
+			if c := visit(n); c != nil {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// libraryCalls collects what library conditions produced during one trace.
+type libraryCalls struct {
+	subs   map[string]*TraceResult // sub-trace per spec ID and arguments (see subKey)
+	inputs map[string]interface{}  // env paths read by sub-traces, params excluded
+	calls  int                     // library conditions evaluated so far
+}
+
+// libraryOptions registers the library conditions as functions that trace their
+// expression against the tracer's env with params bound, collecting sub-traces in lc.
+// The Patch option, only needed when compiling the authored input, rewrites references
+// into calls wrapped with Cond so they are traced as single atoms.
+func (t *Tracer) libraryOptions(lc *libraryCalls) (funcs []expr.Option, patch expr.Option) {
+	conds := t.library.snapshot()
+	for _, c := range conds {
+		funcs = append(funcs, expr.Function(c.Name, t.libraryFunc(c, lc)))
+	}
+	return funcs, expr.Patch(&libraryPatcher{conds: conds, env: t.env, wrap: t.enableCond, refs: map[ast.Node]string{}})
+}
+
+func (t *Tracer) libraryFunc(c NamedCondition, lc *libraryCalls) func(params ...any) (any, error) {
+	return func(args ...any) (any, error) {
+		if len(args) != len(c.Params) {
+			return nil, fmt.Errorf("condition %s expects %d argument(s), got %d", c.Name, len(c.Params), len(args))
+		}
+		env := t.env
+		if len(args) > 0 {
+			env = make(map[string]interface{}, len(t.env)+len(args))
+			for k, v := range t.env {
+				env[k] = v
+			}
+			for i, p := range c.Params {
+				env[p] = args[i]
+			}
+		}
+		sub := *t
+		sub.env = env
+		if len(args) > 0 {
+			sub.shared = nil // results under bound params are not valid for the session env
+		}
+		lc.calls++
+		res := sub.Trace(c.Expr, c.Specs)
+		lc.subs[subKey(c.Spec.ID, args)] = &res
+		for path, v := range res.Inputs {
+			if name, _, err := access.Split(path); err == nil && !contains(c.Params, name) {
+				lc.inputs[path] = v
+			}
+		}
+
+		// A spec with ReasonUnknown wraps the reference in Cond3, which records nil as unknown.
+		return boolFinal("condition "+c.Name, res, c.Spec.ReasonUnknown != "")
+	}
+}
+
+// subKey keys a library sub-trace by the reference's spec ID and the argument values
+// it was called with, so `is_owner(a) || is_owner(b)` keeps both traces.
+func subKey(id string, args []any) string {
+	if len(args) == 0 {
+		return id
+	}
+	return id + fmt.Sprintf("%#v", args)
+}
+
+// librarySub returns the sub-trace of the library reference in a Cond chunk, evaluating
+// the reference's arguments to find the call it came from.
+func (t *Tracer) librarySub(lc *libraryCalls, id, exprStr string, fmter *format.Formatter, ec *eval.Cache, opts ...expr.Option) *TraceResult {
+	if sub, ok := lc.subs[id]; ok {
+		return sub
+	}
+	tree, err := parser.Parse(exprStr)
+	if err != nil {
+		return nil
+	}
+	cond, ok := tree.Node.(*ast.CallNode)
+	if !ok || len(cond.Arguments) == 0 {
+		return nil
+	}
+	call, ok := cond.Arguments[len(cond.Arguments)-1].(*ast.CallNode)
+	if !ok {
+		return nil
+	}
+	args := make([]any, len(call.Arguments))
+	for i, a := range call.Arguments {
+		v, errStr := eval.EvalString(fmter.Format(a), t.env, ec, opts...)
+		if errStr != "" {
+			return nil
+		}
+		args[i] = v
+	}
+	return lc.subs[subKey(id, args)]
+}
+
+// libraryPatcher rewrites `name` and `name(args)` references to library conditions into
+// Cond(id, rT, rF, name(args)). ast.Walk visits a call's callee before the call, so a
+// callee first gets rewritten like an identifier and is unwrapped again at the call.
+type libraryPatcher struct {
+	conds map[string]NamedCondition
+	env   map[string]interface{}
+	wrap  bool
+	refs  map[ast.Node]string // rewritten identifiers -> name
+}
+
+func (p *libraryPatcher) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		c, ok := p.conds[n.Value]
+		if _, inEnv := p.env[n.Value]; !ok || inEnv {
+			return
+		}
+		ref := p.reference(c, nil)
+		p.refs[ref] = c.Name
+		ast.Patch(node, ref)
+
+	case *ast.CallNode:
+		name, ok := p.refs[n.Callee]
+		if !ok {
+			return
+		}
+		delete(p.refs, n.Callee)
+		ast.Patch(node, p.reference(p.conds[name], n.Arguments))
+	}
+}
+
+func (p *libraryPatcher) reference(c NamedCondition, args []ast.Node) ast.Node {
+	call := &ast.CallNode{Callee: &ast.IdentifierNode{Value: c.Name}, Arguments: args}
+	if !p.wrap {
+		return call
+	}
+	s := c.Spec
+	if s.ReasonUnknown == "" {
+		return &ast.CallNode{
+			Callee: &ast.IdentifierNode{Value: "Cond"},
+			Arguments: []ast.Node{
+				&ast.StringNode{Value: s.ID},
+				&ast.StringNode{Value: s.ReasonTrue},
+				&ast.StringNode{Value: s.ReasonFalse},
+				call,
+			},
+		}
+	}
+	return &ast.CallNode{
+		Callee: &ast.IdentifierNode{Value: "Cond3"},
+		Arguments: []ast.Node{
+			&ast.StringNode{Value: s.ID},
+			&ast.StringNode{Value: s.ReasonTrue},
+			&ast.StringNode{Value: s.ReasonFalse},
+			&ast.StringNode{Value: s.ReasonUnknown},
+			call,
+		},
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package ruletrace
+
+import (
+	"strings"
+	"testing"
+)
+
+func ownerLibrary(t *testing.T) *Library {
+	t.Helper()
+	lib, err := NewLibrary(
+		NamedCondition{
+			Name: "is_owner", Params: []string{"u"}, Expr: `u.Id == post.OwnerId`,
+			Spec: ConditionSpec{ID: "c_owner", ReasonTrue: "OWNER", ReasonFalse: "NOT_OWNER"},
+		},
+		NamedCondition{Name: "published", Expr: `post.Published`},
+	)
+	if err != nil {
+		t.Fatalf("NewLibrary: %v", err)
+	}
+	return lib
+}
+
+func TestLibrarySubPerCall(t *testing.T) {
+	env := map[string]interface{}{
+		"author": map[string]interface{}{"Id": 1},
+		"editor": map[string]interface{}{"Id": 2},
+		"post":   map[string]interface{}{"OwnerId": 2, "Published": true},
+	}
+	tests := []struct {
+		name   string
+		rule   string
+		finals []interface{} // Final of each chunk's Sub
+	}{
+		{"two calls", `is_owner(author) || is_owner(editor)`, []interface{}{false, true}},
+		{"two calls reversed", `is_owner(editor) && is_owner(author)`, []interface{}{true, false}},
+		{"no params", `published && is_owner(editor)`, []interface{}{true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := New(env, WithLibrary(ownerLibrary(t))).Trace(tt.rule, nil)
+			if len(res.Chunks) != len(tt.finals) {
+				t.Fatalf("got %d chunks, want %d: %+v", len(res.Chunks), len(tt.finals), res.Chunks)
+			}
+			for i, want := range tt.finals {
+				c := res.Chunks[i]
+				if c.Sub == nil {
+					t.Fatalf("chunk %d (%s) has no Sub", i, c.Expr)
+				}
+				if c.Sub.Final != want || c.Value != want {
+					t.Errorf("chunk %d (%s): value %v, Sub.Final %v, want %v", i, c.Expr, c.Value, c.Sub.Final, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLibrarySet(t *testing.T) {
+	tests := []struct {
+		name string
+		cond NamedCondition
+		err  string
+	}{
+		{"invalid name", NamedCondition{Name: "is-owner", Expr: `true`}, "invalid condition name"},
+		{"reserved name", NamedCondition{Name: "Cond", Expr: `true`}, "invalid condition name"},
+		{"builtin", NamedCondition{Name: "len", Expr: `true`}, "builtin"},
+		{"duplicate param", NamedCondition{Name: "x", Params: []string{"a", "a"}, Expr: `a`}, "duplicate param"},
+		{"parse error", NamedCondition{Name: "x", Expr: `a &&`}, `condition "x"`},
+		{"cycle", NamedCondition{Name: "published", Expr: `is_owner(post.Owner) && published`}, "reference cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lib := ownerLibrary(t)
+			err := lib.Set(tt.cond)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Set = %v, want error containing %q", err, tt.err)
+			}
+			if c, _ := lib.Lookup("published"); c.Expr != `post.Published` {
+				t.Errorf("failed Set replaced published with %q", c.Expr)
+			}
+		})
+	}
+}
@@ -0,0 +1,599 @@
+package ruletrace
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/format"
+	"github.com/aqilarik/ruletrace/internal/patch"
+)
+
+// Finding codes reported by Lint.
+const (
+	LintContradiction   = "contradiction"     // atoms that cannot all hold (`x > 5 && x < 3`)
+	LintTautology       = "tautology"         // atoms of which one always holds (`x > 5 || x <= 5`)
+	LintDuplicateAtom   = "duplicate-atom"    // the same atom twice in one `||`/`&&` chain
+	LintUnreachable     = "unreachable"       // a branch no evaluation reaches (`true || x`)
+	LintTypeMismatch    = "type-mismatch"     // literals of different types compared to each other or one operand
+	LintDuplicateInItem = "duplicate-in-item" // an `in` list holding the same literal twice
+	LintFloatEquality   = "float-equality"    // `==` or `!=` against a float literal
+	LintUnusedSpec      = "unused-spec"       // a spec whose fingerprint matches no atom
+)
+
+// Finding severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Finding is one authoring mistake found by Lint.
+type Finding struct {
+	Code     string
+	Severity string
+	Pos      int // rune offset in the input where the offending expression starts; NoPos for unused-spec
+	Message  string
+}
+
+// NoPos is the Pos of findings about the specs rather than a place in the input.
+const NoPos = -1
+
+func (f Finding) String() string {
+	if f.Pos == NoPos {
+		return fmt.Sprintf("%s: %s (%s)", f.Severity, f.Message, f.Code)
+	}
+	return fmt.Sprintf("%d: %s: %s (%s)", f.Pos, f.Severity, f.Message, f.Code)
+}
+
+// Lint checks input for common authoring mistakes, statically: nothing is evaluated
+// against an env. Contradictions and tautologies are found between atoms comparing the
+// same operand with literals (`x > 5`, `x == "a"`, `x in [1, 2]`) within one `&&` or `||`
+// chain, and in atoms that compare literals only. When every number an operand is
+// compared with is an integer literal, the operand is also checked as an integer: `x > 5
+// && x < 6` is reported, as a warning, since a float x could still satisfy it. Findings
+// are sorted by position, findings without one (NoPos) last.
+func Lint(input string, specs map[string]ConditionSpec) ([]Finding, error) {
+	tree, err := parser.Parse(input)
+	if err != nil {
+		return nil, fmt.Errorf("lint: %w", err)
+	}
+	l := &linter{fmter: format.New(), kinds: map[string]map[string]int{}}
+	l.node(tree.Node)
+	l.typeMismatches()
+
+	atoms := map[string]bool{}
+	for _, a := range patch.CollectAtoms(tree.Node) {
+		atoms[Fingerprint(l.fmter.Format(a))] = true
+	}
+	fps := make([]string, 0, len(specs))
+	for fp := range specs {
+		fps = append(fps, fp)
+	}
+	sort.Strings(fps)
+	for _, fp := range fps {
+		if !atoms[fp] {
+			l.add(LintUnusedSpec, SeverityWarning, NoPos, "spec %q matches no atom of the rule", specs[fp].ID)
+		}
+	}
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		pi, pj := l.findings[i].Pos, l.findings[j].Pos
+		if pi == NoPos || pj == NoPos {
+			return pj == NoPos && pi != NoPos
+		}
+		return pi < pj
+	})
+	return l.findings, nil
+}
+
+type linter struct {
+	fmter    *format.Formatter
+	findings []Finding
+	kinds    map[string]map[string]int // operand -> literal kind -> first position
+}
+
+func (l *linter) add(code, severity string, pos int, msg string, args ...interface{}) {
+	l.findings = append(l.findings, Finding{Code: code, Severity: severity, Pos: pos, Message: fmt.Sprintf(msg, args...)})
+}
+
+// node lints n and everything below it. Chains of one logical operator are linted as a
+// whole from their topmost node.
+func (l *linter) node(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.BinaryNode:
+		switch n.Operator {
+		case "||", "or", "&&", "and":
+			l.chain(n)
+			return
+		case "??":
+			if v, ok := literalValue(n.Left); ok && v != nil {
+				l.add(LintUnreachable, SeverityWarning, start(n.Right), "%s is unreachable: the left side of ?? is never nil", l.fmter.Format(n.Right))
+			}
+		}
+		l.atom(n)
+	case *ast.ConditionalNode:
+		if b, ok := literalBool(unparen(n.Cond)); ok {
+			dead := n.Exp2
+			if !b {
+				dead = n.Exp1
+			}
+			l.add(LintUnreachable, SeverityWarning, start(dead), "%s is unreachable: the condition is always %v", l.fmter.Format(dead), b)
+		}
+	}
+	for _, c := range children(n) {
+		l.node(c)
+	}
+}
+
+// chain lints a flattened `||` or `&&` chain: duplicates, constants cutting it short,
+// and contradictions (for `&&`) or tautologies (for `||`) between its atoms.
+func (l *linter) chain(n *ast.BinaryNode) {
+	or := n.Operator == "||" || n.Operator == "or"
+	ops := flatten(n, or)
+
+	seen := map[string]bool{}
+	for i, op := range ops {
+		src := l.fmter.Format(op)
+		if seen[src] {
+			l.add(LintDuplicateAtom, SeverityWarning, start(op), "%s appears twice in the same chain", src)
+		}
+		seen[src] = true
+		if b, ok := literalBool(op); ok && b == or && i+1 < len(ops) {
+			l.add(LintUnreachable, SeverityWarning, start(ops[i+1]), "operands after %v are unreachable", b)
+		}
+	}
+
+	// Group atoms by operand; for `||`, the chain is a tautology if the negated atoms
+	// contradict each other.
+	type group struct {
+		d     domain
+		atoms []string
+		pos   int
+	}
+	groups := map[string]*group{}
+	var order []string
+	for _, op := range ops {
+		subject, d, ok := constraint(op, l.fmter)
+		if !ok {
+			continue
+		}
+		if or {
+			if d, ok = d.negate(); !ok {
+				continue
+			}
+		}
+		g, exists := groups[subject]
+		if !exists {
+			g = &group{d: anything(), pos: start(op)}
+			groups[subject] = g
+			order = append(order, subject)
+		}
+		g.d = g.d.intersect(d)
+		g.atoms = append(g.atoms, l.fmter.Format(op))
+	}
+	for _, subject := range order {
+		g := groups[subject]
+		if len(g.atoms) < 2 {
+			continue
+		}
+		atoms := strings.Join(g.atoms, ", ")
+		switch {
+		case g.d.empty() && !or:
+			l.add(LintContradiction, SeverityError, g.pos, "%s cannot all hold at once: the rule is always false", atoms)
+		case g.d.empty() && or:
+			l.add(LintTautology, SeverityWarning, g.pos, "one of %s always holds: the chain is always true", atoms)
+		case g.d.ints && g.d.emptyOverInts() && !or:
+			l.add(LintContradiction, SeverityWarning, g.pos, "%s cannot all hold at once for an integer %s: the rule is always false", atoms, subject)
+		case g.d.ints && g.d.emptyOverInts() && or:
+			l.add(LintTautology, SeverityWarning, g.pos, "one of %s always holds for an integer %s: the chain is always true", atoms, subject)
+		}
+	}
+
+	for _, op := range ops {
+		l.node(op)
+	}
+}
+
+// atom lints a single comparison.
+func (l *linter) atom(n ast.Node) {
+	bn, ok := unparen(n).(*ast.BinaryNode)
+	if !ok || !patch.IsAtomNode(bn) {
+		return
+	}
+	src := l.fmter.Format(bn)
+	lk, lok := literalKind(bn.Left)
+	rk, rok := literalKind(bn.Right)
+
+	switch {
+	case lok && rok:
+		if lk != rk && bn.Operator != "in" {
+			l.add(LintTypeMismatch, SeverityError, start(bn), "%s compares a %s with a %s", src, lk, rk)
+		} else if v, err := expr.Eval(src, nil); err == nil {
+			if b, ok := v.(bool); ok && b {
+				l.add(LintTautology, SeverityWarning, start(bn), "%s is always true", src)
+			} else if ok {
+				l.add(LintContradiction, SeverityError, start(bn), "%s is always false", src)
+			}
+		}
+	case !lok && !rok && l.fmter.Format(bn.Left) == l.fmter.Format(bn.Right):
+		switch bn.Operator {
+		case "==", "<=", ">=":
+			l.add(LintTautology, SeverityWarning, start(bn), "%s compares an operand with itself: always true", src)
+		case "!=", "<", ">":
+			l.add(LintContradiction, SeverityError, start(bn), "%s compares an operand with itself: always false", src)
+		}
+	}
+
+	if (bn.Operator == "==" || bn.Operator == "!=") && (isFloat(bn.Left) || isFloat(bn.Right)) {
+		l.add(LintFloatEquality, SeverityWarning, start(bn), "%s tests a float for exact equality", src)
+	}
+
+	if arr, ok := bn.Right.(*ast.ArrayNode); ok && bn.Operator == "in" {
+		seen := map[interface{}]bool{}
+		for _, e := range arr.Nodes {
+			v, ok := literalValue(e)
+			if !ok {
+				continue
+			}
+			v = normalize(v)
+			if seen[v] {
+				l.add(LintDuplicateInItem, SeverityWarning, start(e), "%s is listed twice in %s", l.fmter.Format(e), l.fmter.Format(arr))
+			}
+			seen[v] = true
+		}
+	}
+
+	// Remember which literal kinds each operand is compared with, across the rule.
+	subject, lit := bn.Left, bn.Right
+	if lok && !rok {
+		subject, lit = bn.Right, bn.Left
+	}
+	if _, ok := literalKind(subject); ok {
+		return
+	}
+	kinds := []string{}
+	if k, ok := literalKind(lit); ok {
+		kinds = append(kinds, k)
+	} else if arr, ok := lit.(*ast.ArrayNode); ok && bn.Operator == "in" {
+		for _, e := range arr.Nodes {
+			if k, ok := literalKind(e); ok {
+				kinds = append(kinds, k)
+			}
+		}
+	}
+	key := l.fmter.Format(subject)
+	for _, k := range kinds {
+		if k == "nil" { // nil checks go with any type
+			continue
+		}
+		if l.kinds[key] == nil {
+			l.kinds[key] = map[string]int{}
+		}
+		if _, ok := l.kinds[key][k]; !ok {
+			l.kinds[key][k] = start(bn)
+		}
+	}
+}
+
+// typeMismatches reports operands compared with literals of different kinds.
+func (l *linter) typeMismatches() {
+	keys := make([]string, 0, len(l.kinds))
+	for k := range l.kinds {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		kinds := l.kinds[key]
+		if len(kinds) < 2 {
+			continue
+		}
+		names := make([]string, 0, len(kinds))
+		pos := math.MaxInt
+		for k, p := range kinds {
+			names = append(names, k)
+			if p < pos {
+				pos = p
+			}
+		}
+		sort.Strings(names)
+		l.add(LintTypeMismatch, SeverityWarning, pos, "%s is compared with literals of different types (%s)", key, strings.Join(names, ", "))
+	}
+}
+
+// flatten returns the operands of the chain of n's operator, in order.
+func flatten(n ast.Node, or bool) []ast.Node {
+	n = unparen(n)
+	if bn, ok := n.(*ast.BinaryNode); ok {
+		isOr := bn.Operator == "||" || bn.Operator == "or"
+		isAnd := bn.Operator == "&&" || bn.Operator == "and"
+		if (or && isOr) || (!or && isAnd) {
+			return append(flatten(bn.Left, or), flatten(bn.Right, or)...)
+		}
+	}
+	return []ast.Node{n}
+}
+
+func unparen(n ast.Node) ast.Node {
+	if ch, ok := n.(*ast.ChainNode); ok {
+		return unparen(ch.Node)
+	}
+	return n
+}
+
+// children returns the direct children of n that lint descends into.
+func children(n ast.Node) []ast.Node {
+	switch n := n.(type) {
+	case *ast.BinaryNode:
+		return []ast.Node{n.Left, n.Right}
+	case *ast.UnaryNode:
+		return []ast.Node{n.Node}
+	case *ast.ConditionalNode:
+		return []ast.Node{n.Cond, n.Exp1, n.Exp2}
+	case *ast.ChainNode:
+		return []ast.Node{n.Node}
+	case *ast.CallNode:
+		return n.Arguments
+	case *ast.BuiltinNode:
+		return n.Arguments
+	case *ast.PredicateNode:
+		return []ast.Node{n.Node}
+	case *ast.SequenceNode:
+		return n.Nodes
+	case *ast.VariableDeclaratorNode:
+		return []ast.Node{n.Value, n.Expr}
+	}
+	return nil
+}
+
+// start returns the smallest source offset in n's subtree.
+func start(n ast.Node) int {
+	pos := n.Location().From
+	ast.Find(n, func(x ast.Node) bool {
+		if f := x.Location().From; f < pos {
+			pos = f
+		}
+		return false
+	})
+	return pos
+}
+
+func literalKind(n ast.Node) (string, bool) {
+	switch n.(type) {
+	case *ast.IntegerNode, *ast.FloatNode:
+		return "number", true
+	case *ast.StringNode:
+		return "string", true
+	case *ast.BoolNode:
+		return "bool", true
+	case *ast.NilNode:
+		return "nil", true
+	}
+	return "", false
+}
+
+func isFloat(n ast.Node) bool {
+	_, ok := n.(*ast.FloatNode)
+	return ok
+}
+
+// normalize makes numeric literals comparable as map keys.
+func normalize(v interface{}) interface{} {
+	if i, ok := v.(int); ok {
+		return float64(i)
+	}
+	return v
+}
+
+// domain is the set of values an operand may take under some atoms: a numeric interval,
+// optionally restricted to a finite set (only) and minus excluded values (not).
+type domain struct {
+	lo, hi         float64
+	loOpen, hiOpen bool
+	only           map[interface{}]bool // nil: unrestricted
+	not            map[interface{}]bool
+	ints           bool // every number the atoms compare with is an integer literal
+}
+
+func anything() domain {
+	return domain{lo: math.Inf(-1), hi: math.Inf(1), loOpen: true, hiOpen: true, not: map[interface{}]bool{}, ints: true}
+}
+
+// constraint returns the operand an atom compares with literals, and the values the
+// operand may take for the atom to hold.
+func constraint(n ast.Node, fmter *format.Formatter) (string, domain, bool) {
+	n = unparen(n)
+	if un, ok := n.(*ast.UnaryNode); ok && (un.Operator == "not" || un.Operator == "!") {
+		s, d, ok := constraint(un.Node, fmter)
+		if !ok {
+			return "", domain{}, false
+		}
+		d, ok = d.negate()
+		return s, d, ok
+	}
+	bn, ok := n.(*ast.BinaryNode)
+	if !ok {
+		return "", domain{}, false
+	}
+	subject, lit, op := bn.Left, bn.Right, bn.Operator
+	if _, ok := literalKind(subject); ok {
+		subject, lit = lit, subject
+		op = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<=", "==": "==", "!=": "!="}[op]
+	}
+	if _, ok := literalKind(subject); ok {
+		return "", domain{}, false
+	}
+
+	d := anything()
+	if op == "in" {
+		arr, ok := lit.(*ast.ArrayNode)
+		if !ok {
+			return "", domain{}, false
+		}
+		d.only = map[interface{}]bool{}
+		for _, e := range arr.Nodes {
+			v, ok := literalValue(e)
+			if !ok {
+				return "", domain{}, false
+			}
+			d.only[normalize(v)] = true
+			d.ints = d.ints && !isFloat(e)
+		}
+		return fmter.Format(subject), d, true
+	}
+
+	v, ok := literalValue(lit)
+	if !ok {
+		return "", domain{}, false
+	}
+	v = normalize(v)
+	f, numeric := v.(float64)
+	d.ints = !isFloat(lit)
+	switch {
+	case op == "==":
+		d.only = map[interface{}]bool{v: true}
+	case op == "!=":
+		d.not[v] = true
+	case numeric && op == ">":
+		d.lo, d.loOpen = f, true
+	case numeric && op == ">=":
+		d.lo, d.loOpen = f, false
+	case numeric && op == "<":
+		d.hi, d.hiOpen = f, true
+	case numeric && op == "<=":
+		d.hi, d.hiOpen = f, false
+	default:
+		return "", domain{}, false
+	}
+	return fmter.Format(subject), d, true
+}
+
+// negate returns the complement of d, when it is a domain: a half-line, a single
+// restriction to or exclusion of values.
+func (d domain) negate() (domain, bool) {
+	out := anything()
+	out.ints = d.ints
+	bounded := !math.IsInf(d.lo, -1) || !math.IsInf(d.hi, 1)
+	switch {
+	case d.only != nil && !bounded && len(d.not) == 0:
+		for v := range d.only {
+			out.not[v] = true
+		}
+	case d.only == nil && !bounded && len(d.not) > 0:
+		out.only = map[interface{}]bool{}
+		for v := range d.not {
+			out.only[v] = true
+		}
+	case d.only == nil && len(d.not) == 0 && math.IsInf(d.hi, 1) && !math.IsInf(d.lo, -1):
+		out.hi, out.hiOpen = d.lo, !d.loOpen
+	case d.only == nil && len(d.not) == 0 && math.IsInf(d.lo, -1) && !math.IsInf(d.hi, 1):
+		out.lo, out.loOpen = d.hi, !d.hiOpen
+	default:
+		return domain{}, false
+	}
+	return out, true
+}
+
+func (d domain) intersect(e domain) domain {
+	out := d
+	if e.lo > out.lo || (e.lo == out.lo && e.loOpen) {
+		out.lo, out.loOpen = e.lo, e.loOpen
+	}
+	if e.hi < out.hi || (e.hi == out.hi && e.hiOpen) {
+		out.hi, out.hiOpen = e.hi, e.hiOpen
+	}
+	switch {
+	case out.only == nil:
+		out.only = e.only
+	case e.only != nil:
+		only := map[interface{}]bool{}
+		for v := range out.only {
+			if e.only[v] {
+				only[v] = true
+			}
+		}
+		out.only = only
+	}
+	not := map[interface{}]bool{}
+	for v := range d.not {
+		not[v] = true
+	}
+	for v := range e.not {
+		not[v] = true
+	}
+	out.not = not
+	out.ints = d.ints && e.ints
+	return out
+}
+
+// empty reports whether no value satisfies d. Non-numeric values are only bounded by
+// only and not.
+func (d domain) empty() bool {
+	if d.only != nil {
+		for v := range d.only {
+			if !d.not[v] && d.contains(v) {
+				return false
+			}
+		}
+		return true
+	}
+	if d.lo > d.hi || (d.lo == d.hi && (d.loOpen || d.hiOpen)) {
+		return true
+	}
+	return d.lo == d.hi && d.not[d.lo]
+}
+
+// emptyOverInts reports whether no integer satisfies d: open bounds are moved to the
+// nearest integer inside, and a short range is checked value by value against not.
+func (d domain) emptyOverInts() bool {
+	if d.only != nil {
+		for v := range d.only {
+			if f, ok := v.(float64); (!ok || f == math.Trunc(f)) && !d.not[v] && d.contains(v) {
+				return false
+			}
+		}
+		return true
+	}
+	lo, hi := d.lo, d.hi
+	switch {
+	case math.IsInf(lo, -1):
+	case d.loOpen:
+		lo = math.Floor(lo) + 1
+	default:
+		lo = math.Ceil(lo)
+	}
+	switch {
+	case math.IsInf(hi, 1):
+	case d.hiOpen:
+		hi = math.Ceil(hi) - 1
+	default:
+		hi = math.Floor(hi)
+	}
+	if lo > hi {
+		return true
+	}
+	if math.IsInf(lo, -1) || math.IsInf(hi, 1) || hi-lo >= float64(len(d.not)) {
+		return false
+	}
+	for x := lo; x <= hi; x++ {
+		if !d.not[x] {
+			return false
+		}
+	}
+	return true
+}
+
+func (d domain) contains(v interface{}) bool {
+	f, ok := v.(float64)
+	if !ok {
+		return true
+	}
+	if f < d.lo || (f == d.lo && d.loOpen) {
+		return false
+	}
+	return f < d.hi || (f == d.hi && !d.hiOpen)
+}
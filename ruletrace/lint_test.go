@@ -0,0 +1,69 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	type want struct {
+		code, severity string
+		pos            int
+	}
+	tests := []struct {
+		rule string
+		want []want
+	}{
+		{`x > 5 && x < 3`, []want{{LintContradiction, SeverityError, 0}}},
+		{`x > 5 && x < 6`, []want{{LintContradiction, SeverityWarning, 0}}},
+		{`x > 5.0 && x < 6`, nil},
+		{`x >= 5 && x <= 6 && x != 5 && x != 6`, []want{{LintContradiction, SeverityWarning, 0}}},
+		{`x >= 5 && x <= 7 && x != 5 && x != 6`, nil},
+		{`x in [1, 2] && x > 1 && x < 2`, []want{{LintContradiction, SeverityError, 0}}},
+		{`x <= 5 || x >= 6`, []want{{LintTautology, SeverityWarning, 0}}},
+		{`x <= 5 || x > 5`, []want{{LintTautology, SeverityWarning, 0}}},
+		{`x <= 5 || x >= 6.5`, nil},
+		{`y == 1 && x == "a" && x == "b"`, []want{{LintContradiction, SeverityError, 10}}},
+		{`a && a`, []want{{LintDuplicateAtom, SeverityWarning, 5}}},
+		{`true || x`, []want{{LintUnreachable, SeverityWarning, 8}}},
+		{`1 == "1"`, []want{{LintTypeMismatch, SeverityError, 0}}},
+		{`x in [1, 2, 1]`, []want{{LintDuplicateInItem, SeverityWarning, 12}}},
+		{`x == 0.1`, []want{{LintFloatEquality, SeverityWarning, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			findings, err := Lint(tt.rule, nil)
+			if err != nil {
+				t.Fatalf("Lint: %v", err)
+			}
+			var got []want
+			for _, f := range findings {
+				got = append(got, want{f.Code, f.Severity, f.Pos})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings = %v, want %v\n%v", got, tt.want, findings)
+			}
+		})
+	}
+}
+
+func TestLintUnusedSpec(t *testing.T) {
+	specs := map[string]ConditionSpec{
+		Fingerprint(`x > 1`): {ID: "c_x"},
+		Fingerprint(`y > 1`): {ID: "c_y"},
+	}
+	findings, err := Lint(`x > 1 && x > 1`, specs)
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("findings = %v, want duplicate-atom then unused-spec", findings)
+	}
+	last := findings[1]
+	if last.Code != LintUnusedSpec || last.Pos != NoPos {
+		t.Errorf("last finding = %+v, want unused-spec at NoPos", last)
+	}
+	if got, want := last.String(), `warning: spec "c_y" matches no atom of the rule (unused-spec)`; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,278 @@
+package ruletrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/access"
+)
+
+// mcdcBudget bounds the envs one generation traces.
+const mcdcBudget = 4096
+
+// MCDCSuite is a set of envs giving modified condition/decision coverage of a rule.
+type MCDCSuite struct {
+	Rule      string    `json:"rule"`
+	Atoms     []string  `json:"atoms"`               // the rule's atoms in trace order: spec ID, else source
+	Fixtures  []Fixture `json:"fixtures"`            // the envs, with what the rule should give on each
+	Uncovered []string  `json:"uncovered,omitempty"` // atoms no pair of envs shows to matter on its own
+}
+
+// Fixture is one generated test case.
+type Fixture struct {
+	Name    string                 `json:"name"`
+	Env     map[string]interface{} `json:"env"`
+	Final   interface{}            `json:"final"`
+	Reasons []string               `json:"reasons,omitempty"` // reason codes of the chunks, in trace order
+	Shows   []string               `json:"shows"`             // atoms this fixture is half of a pair for
+}
+
+// Golden returns the suite as indented JSON, for a golden test file.
+func (s MCDCSuite) Golden() ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false) // keep `&&` and `>` readable in the rule and atoms
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// MCDC generates a small set of envs in which each atom of rule is shown to affect the
+// outcome on its own: for every atom, two fixtures where it is evaluated with opposite
+// values and Final flips, while every other atom keeps its value or is short-circuited
+// in one of the two (short-circuit MC/DC). Each fixture records the expected Final and
+// the reason codes specs give its chunks.
+//
+// Candidate envs come from the literals the atoms compare paths with, as in Solve, and
+// are traced up to a budget; pairs are then picked greedily, preferring envs already in
+// the suite, so the set is small but not always minimal. Atoms that cannot be toggled
+// alone (`x > 1 && x > 0` for `x > 0`) or whose candidates miss the case are listed in
+// Uncovered, so an uncovered string test (substring, pattern or order, where Solve is
+// not Complete either) may only mean no candidate hit it. Env paths no atom reads (the
+// condition of `?:`) are held equal within a pair. opts apply to tracing the rule.
+func MCDC(rule string, specs map[string]ConditionSpec, opts ...Option) (MCDCSuite, error) {
+	tree, err := parser.Parse(rule)
+	if err != nil {
+		return MCDCSuite{}, fmt.Errorf("mcdc: %w", err)
+	}
+	t := New(nil, append(opts, WithMode(TraceAtomic))...)
+	d := newDeps(t.library, t.registryRules())
+	units, err := d.units(rule)
+	if err != nil {
+		return MCDCSuite{}, fmt.Errorf("mcdc: %w", err)
+	}
+	vars, _ := t.candidates(tree.Node)
+	var read []string
+	for _, u := range units {
+		read = append(read, d.atomPaths(u, nil)...)
+	}
+	g := &mcdcGen{rule: rule, specs: specs, opts: append(opts, WithMode(TraceAtomic)), d: d, units: len(units), seen: map[string]bool{}}
+	for _, v := range vars {
+		if !overlapsAny(v.path, read) {
+			g.fixed = append(g.fixed, v.path)
+		}
+	}
+	g.enumerate(vars, map[string]interface{}{})
+
+	suite := MCDCSuite{Rule: rule, Atoms: make([]string, len(units))}
+	for i, u := range units {
+		suite.Atoms[i] = u
+		if s, ok := specs[Fingerprint(u)]; ok && s.ID != "" {
+			suite.Atoms[i] = s.ID
+		}
+	}
+	chosen, shows := g.pick()
+	for i, c := range chosen {
+		f := Fixture{Name: fmt.Sprintf("mcdc-%02d", i+1), Env: g.cases[c].env, Final: g.cases[c].final, Reasons: g.cases[c].reasons}
+		for _, a := range shows[c] {
+			f.Shows = append(f.Shows, suite.Atoms[a])
+		}
+		suite.Fixtures = append(suite.Fixtures, f)
+	}
+	covered := map[int]bool{}
+	for _, as := range shows {
+		for _, a := range as {
+			covered[a] = true
+		}
+	}
+	for i, a := range suite.Atoms {
+		if !covered[i] {
+			suite.Uncovered = append(suite.Uncovered, a)
+		}
+	}
+	return suite, nil
+}
+
+// atomState is what one atom did in a trace.
+type atomState struct {
+	skipped bool
+	value   interface{} // when not skipped; nil when it errored
+}
+
+// mcdcCase is a traced candidate env.
+type mcdcCase struct {
+	env     map[string]interface{}
+	final   interface{}
+	reasons []string
+	atoms   []atomState
+	fixed   string // values of the paths no atom reads
+}
+
+type mcdcGen struct {
+	rule   string
+	specs  map[string]ConditionSpec
+	opts   []Option
+	d      *deps
+	units  int
+	fixed  []string // paths no atom reads
+	cases  []mcdcCase
+	seen   map[string]bool // signatures of the cases kept
+	traced int
+}
+
+// enumerate traces every assignment of vars, keeping one env per distinct outcome.
+func (g *mcdcGen) enumerate(vars []solveVar, assigned map[string]interface{}) {
+	if g.traced >= mcdcBudget {
+		return
+	}
+	if len(vars) > 0 {
+		for _, v := range vars[0].values {
+			assigned[vars[0].path] = v
+			g.enumerate(vars[1:], assigned)
+		}
+		delete(assigned, vars[0].path)
+		return
+	}
+	g.traced++
+	env, err := access.Expand(assigned)
+	if err != nil {
+		return
+	}
+	res := New(env, g.opts...).Trace(g.rule, g.specs)
+	c := mcdcCase{env: env, final: res.Final, atoms: g.states(res.Chunks)}
+	if c.atoms == nil {
+		return
+	}
+	for _, p := range g.fixed {
+		c.fixed += fmt.Sprintf("%s=%v;", p, assigned[p])
+	}
+	for _, ch := range res.Chunks {
+		if ch.Reason != "" {
+			c.reasons = append(c.reasons, ch.Reason)
+		}
+	}
+	sig := fmt.Sprintf("%v|%v|%s", c.final, c.atoms, c.fixed)
+	if !g.seen[sig] {
+		g.seen[sig] = true
+		g.cases = append(g.cases, c)
+	}
+}
+
+// states lines chunks up with the rule's atoms: a skipped chunk stands for every atom of
+// its subtree. It returns nil when they do not line up.
+func (g *mcdcGen) states(chunks []EvalResult) []atomState {
+	out := make([]atomState, 0, g.units)
+	for _, c := range chunks {
+		if !c.Skipped {
+			v := c.Value
+			if c.Error != "" {
+				v = nil
+			}
+			out = append(out, atomState{value: v})
+			continue
+		}
+		us, err := g.d.units(c.Expr)
+		if err != nil {
+			return nil
+		}
+		for range us {
+			out = append(out, atomState{skipped: true})
+		}
+	}
+	if len(out) != g.units {
+		return nil
+	}
+	return out
+}
+
+// independent reports whether cases i and j show atom a to matter on its own.
+func (g *mcdcGen) independent(a, i, j int) bool {
+	ci, cj := g.cases[i], g.cases[j]
+	fi, ok1 := ci.final.(bool)
+	fj, ok2 := cj.final.(bool)
+	if !ok1 || !ok2 || fi == fj || ci.fixed != cj.fixed {
+		return false
+	}
+	vi, ok1 := ci.atoms[a].value.(bool)
+	vj, ok2 := cj.atoms[a].value.(bool)
+	if !ok1 || !ok2 || ci.atoms[a].skipped || cj.atoms[a].skipped || vi == vj {
+		return false
+	}
+	for b := range ci.atoms {
+		if b == a || ci.atoms[b].skipped || cj.atoms[b].skipped {
+			continue
+		}
+		if !reflect.DeepEqual(ci.atoms[b].value, cj.atoms[b].value) {
+			return false
+		}
+	}
+	return true
+}
+
+// pick chooses one independence pair per atom, atoms with the fewest pairs first, each
+// time the pair adding the fewest cases not yet chosen. It returns the chosen cases in
+// order of first choice and, per case, the atoms it shows.
+func (g *mcdcGen) pick() (chosen []int, shows map[int][]int) {
+	pairs := make([][][2]int, g.units)
+	for a := range pairs {
+		for i := range g.cases {
+			for j := i + 1; j < len(g.cases); j++ {
+				if g.independent(a, i, j) {
+					pairs[a] = append(pairs[a], [2]int{i, j})
+				}
+			}
+		}
+	}
+	order := make([]int, 0, g.units)
+	for a := range pairs {
+		if len(pairs[a]) > 0 {
+			order = append(order, a)
+		}
+	}
+	sort.SliceStable(order, func(x, y int) bool { return len(pairs[order[x]]) < len(pairs[order[y]]) })
+
+	in := map[int]bool{}
+	shows = map[int][]int{}
+	for _, a := range order {
+		best, cost := pairs[a][0], 3
+		for _, p := range pairs[a] {
+			c := 0
+			for _, k := range p {
+				if !in[k] {
+					c++
+				}
+			}
+			if c < cost {
+				best, cost = p, c
+			}
+		}
+		for _, k := range best {
+			if !in[k] {
+				in[k] = true
+				chosen = append(chosen, k)
+			}
+			shows[k] = append(shows[k], a)
+		}
+	}
+	for _, k := range chosen {
+		sort.Ints(shows[k])
+	}
+	return chosen, shows
+}
@@ -0,0 +1,111 @@
+package ruletrace
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestMCDCGolden(t *testing.T) {
+	specs := map[string]ConditionSpec{
+		Fingerprint(`a > 1`):            {ID: "c_a", ReasonTrue: "A_HIGH", ReasonFalse: "A_LOW"},
+		Fingerprint(`b == "x"`):         {ID: "c_b", ReasonTrue: "B_X", ReasonFalse: "B_OTHER"},
+		Fingerprint(`user.Name != nil`): {ID: "c_name", ReasonTrue: "NAMED", ReasonFalse: "ANONYMOUS"},
+		Fingerprint(`user.Age > 18`):    {ID: "c_age", ReasonTrue: "ADULT", ReasonFalse: "MINOR"},
+	}
+	tests := []struct {
+		file string
+		rule string
+	}{
+		{"mcdc.json", `a > 1 && (b == "x" || c)`},
+		{"mcdc_nil.json", `user.Name != nil && user.Age > 18`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			suite, err := MCDC(tt.rule, specs)
+			if err != nil {
+				t.Fatalf("MCDC: %v", err)
+			}
+			if len(suite.Uncovered) != 0 {
+				t.Fatalf("Uncovered = %q", suite.Uncovered)
+			}
+			got, err := suite.Golden()
+			if err != nil {
+				t.Fatalf("Golden: %v", err)
+			}
+			path := filepath.Join("testdata", tt.file)
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden (run with -update): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("Golden() differs from %s (run with -update):\n%s", path, got)
+			}
+
+			// fixture names and envs must not depend on map order between runs
+			for i := 0; i < 5; i++ {
+				again, err := MCDC(tt.rule, specs)
+				if err != nil {
+					t.Fatalf("MCDC: %v", err)
+				}
+				if b, _ := again.Golden(); !bytes.Equal(b, got) {
+					t.Fatalf("run %d differs:\n%s", i+2, b)
+				}
+			}
+		})
+	}
+}
+
+func TestMCDC(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      string
+		uncovered []string
+	}{
+		{"and", `a > 1 && b`, nil},
+		{"or of and", `a > 1 && (b == "x" || c)`, nil},
+		{"masked", `x > 1 && x > 0`, []string{`x > 0`}},
+		{"membership", `role in ["a", "b"] || admin`, nil},
+		{"contains", `name contains "ab" && name contains "a"`, []string{`name contains "a"`}},
+		{"startsWith", `name startsWith "a" || name endsWith "z"`, nil},
+		{"nil", `user.Name != nil && user.Age > 18`, nil},
+		{"is nil", `x == nil || y`, nil},
+		// no candidate string matches the pattern, so the atom never comes out true
+		{"pattern", `name matches "^a+$" || b`, []string{`name matches "^a+$"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suite, err := MCDC(tt.rule, nil)
+			if err != nil {
+				t.Fatalf("MCDC: %v", err)
+			}
+			if !reflect.DeepEqual(suite.Uncovered, tt.uncovered) {
+				t.Fatalf("Uncovered = %q, want %q", suite.Uncovered, tt.uncovered)
+			}
+			shown := map[string]int{}
+			for _, f := range suite.Fixtures {
+				if res := New(f.Env, allowUndefined()).Trace(tt.rule, nil); !reflect.DeepEqual(res.Final, f.Final) {
+					t.Errorf("%s: Final %v, fixture says %v", f.Name, res.Final, f.Final)
+				}
+				for _, a := range f.Shows {
+					shown[a]++
+				}
+			}
+			for _, a := range suite.Atoms {
+				if n := shown[a]; n != 0 && n != 2 {
+					t.Errorf("atom %q shown by %d fixtures, want a pair", a, n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package ruletrace
+
+import (
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/eval"
+	"github.com/aqilarik/ruletrace/internal/format"
+	"github.com/aqilarik/ruletrace/internal/message"
+	"github.com/aqilarik/ruletrace/internal/patch"
+)
+
+// renderMessages fills EvalResult.Message for evaluated Cond chunks. The template comes
+// from the tracer's Catalog (by reason code and locale) if it has one, otherwise from the
+// spec's template for the chunk's outcome. Atom operands are evaluated only when needed.
+// Template errors are embedded in the chunk like evaluation errors.
+func (t *Tracer) renderMessages(chunks []EvalResult, byID map[string]ConditionSpec, fmter *format.Formatter, ec *eval.Cache, opts ...expr.Option) {
+	for i := range chunks {
+		c := &chunks[i]
+		if c.Skipped || c.ID == "" || (c.Error != "" && !c.Unknown) {
+			continue
+		}
+		src := ""
+		if spec, ok := byID[c.ID]; ok {
+			src = messageTemplate(spec, *c)
+		}
+		if src == "" && t.catalog == nil {
+			continue
+		}
+
+		data := message.Data{ID: c.ID, Reason: c.Reason, Value: c.Value, Env: t.env}
+		if t.catalog != nil || strings.Contains(src, "{{") {
+			t.evalOperands(c.Expr, &data, fmter, ec, opts...)
+		}
+		if t.catalog != nil && c.Reason != "" {
+			lookup := t.catalog.Lookup
+			if cc, ok := t.catalog.(ContextCatalog); ok {
+				lookup = func(locale, reason string, n int) (string, bool) {
+					return cc.LookupContext(locale, c.ID, reason, n)
+				}
+			}
+			if m, ok := lookup(t.locale, c.Reason, data.Count); ok {
+				src = m
+			}
+		}
+		if src == "" {
+			continue
+		}
+
+		msg, err := t.messages.Render(src, data)
+		if err != nil {
+			if c.Error == "" {
+				c.Error = "message: " + err.Error()
+			}
+			continue
+		}
+		c.Message = msg
+	}
+}
+
+// evalOperands fills the operator, operands and plural count of the atom inside a Cond chunk.
+func (t *Tracer) evalOperands(exprStr string, data *message.Data, fmter *format.Formatter, ec *eval.Cache, opts ...expr.Option) {
+	data.Count = 1
+	tree, err := parser.Parse(exprStr)
+	if err != nil {
+		return
+	}
+	op, left, right, ok := patch.CondOperands(tree.Node)
+	if !ok {
+		return
+	}
+	data.Op = op
+	data.Left, _ = eval.EvalString(fmter.Format(left), t.env, ec, opts...)
+	data.Right, _ = eval.EvalString(fmter.Format(right), t.env, ec, opts...)
+	for _, v := range []interface{}{data.Right, data.Left} {
+		if n, ok := toCount(v); ok {
+			data.Count = n
+			break
+		}
+	}
+}
+
+func messageTemplate(s ConditionSpec, c EvalResult) string {
+	if c.Unknown {
+		return s.MessageUnknown
+	}
+	if v, ok := c.Value.(bool); ok && v {
+		return s.MessageTrue
+	}
+	return s.MessageFalse
+}
+
+func toCount(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case uint:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	case float32:
+		return int(n), true
+	case float64:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,45 @@
+package ruletrace
+
+import "testing"
+
+func TestMessageOperands(t *testing.T) {
+	specs := map[string]ConditionSpec{
+		Fingerprint(`user.Age >= 18`): {
+			ID: "c_age", ReasonTrue: "ADULT", ReasonFalse: "MINOR", ReasonUnknown: "AGE_UNKNOWN",
+			MessageFalse:   "age {{.Left}} vs {{.Right}}",
+			MessageUnknown: "age {{.Left}} vs {{.Right}}",
+		},
+	}
+	tests := []struct {
+		name string
+		env  map[string]interface{}
+		want string
+	}{
+		{"value", map[string]interface{}{"user": map[string]interface{}{"Age": 16}}, "age 16 vs 18"},
+		{"nil", map[string]interface{}{"user": map[string]interface{}{"Age": nil}}, "age nil vs 18"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := New(tt.env).Trace(`user.Age >= 18`, specs)
+			if got := res.Chunks[0].Message; got != tt.want {
+				t.Errorf("Message = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogContext(t *testing.T) {
+	cat := NewCatalog("en")
+	cat.Set("en", "MINOR", "too young")
+	cat.SetContext("en", "c_drink", "MINOR", "too young to drink")
+	specs := map[string]ConditionSpec{
+		Fingerprint(`user.Age >= 18`): {ID: "c_vote", ReasonTrue: "ADULT", ReasonFalse: "MINOR"},
+		Fingerprint(`user.Age >= 21`): {ID: "c_drink", ReasonTrue: "ADULT", ReasonFalse: "MINOR"},
+	}
+	env := map[string]interface{}{"user": map[string]interface{}{"Age": 16}}
+	res := New(env, WithCatalog(cat, "en"), WithShortCircuit(false)).Trace(`user.Age >= 18 || user.Age >= 21`, specs)
+	got := []string{res.Chunks[0].Message, res.Chunks[1].Message}
+	if got[0] != "too young" || got[1] != "too young to drink" {
+		t.Errorf("messages = %q", got)
+	}
+}
@@ -0,0 +1,69 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func metadataSpecs() map[string]ConditionSpec {
+	return map[string]ConditionSpec{
+		Fingerprint(`user.Age >= 18`): {ID: "c_age", ReasonTrue: "ADULT", ReasonFalse: "MINOR",
+			Severity: "high", Tags: []string{"age", "legal"}, Description: "User is an adult", Priority: 1},
+		Fingerprint(`user.Banned == false`): {ID: "c_banned", ReasonTrue: "NOT_BANNED", ReasonFalse: "BANNED",
+			Severity: "critical", Priority: 5},
+		Fingerprint(`user.Verified == true`): {ID: "c_verified", ReasonTrue: "VERIFIED", ReasonFalse: "UNVERIFIED", Priority: 1},
+	}
+}
+
+func TestSpecMetadata(t *testing.T) {
+	env := map[string]interface{}{"user": map[string]interface{}{"Age": 20, "Banned": false}}
+	res := New(env, WithMode(TraceAtomic)).Trace(`user.Age >= 18 && user.Banned == false`, metadataSpecs())
+	if len(res.Chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(res.Chunks))
+	}
+	age := res.Chunks[0]
+	if age.ID != "c_age" || age.Severity != "high" || age.Description != "User is an adult" || age.Priority != 1 ||
+		!reflect.DeepEqual(age.Tags, []string{"age", "legal"}) {
+		t.Errorf("c_age chunk = %+v", age)
+	}
+	if b := res.Chunks[1]; b.ID != "c_banned" || b.Severity != "critical" || b.Priority != 5 || b.Tags != nil {
+		t.Errorf("c_banned chunk = %+v", b)
+	}
+
+	// an authored Cond with a spec's ID picks up the spec's metadata too
+	res = New(env).Trace(`Cond("c_age", "A", "M", user.Age >= 18)`, metadataSpecs())
+	if c := res.Chunks[0]; c.Severity != "high" || c.Reason != "A" {
+		t.Errorf("authored Cond chunk = %+v", c)
+	}
+}
+
+func TestPrimaryReason(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		user map[string]interface{}
+		want string
+	}{
+		{"highest priority wins", `user.Age >= 18 && user.Banned == false`, map[string]interface{}{"Age": 20, "Banned": false}, "NOT_BANNED"},
+		{"only decisive chunks", `user.Age >= 18 && user.Banned == false`, map[string]interface{}{"Age": 20, "Banned": true}, "BANNED"},
+		{"skipped chunks ignored", `user.Age >= 18 && user.Banned == false`, map[string]interface{}{"Age": 10, "Banned": true}, "MINOR"},
+		{"tie keeps evaluation order", `user.Verified == true || user.Age >= 18`, map[string]interface{}{"Verified": false, "Age": 10}, "UNVERIFIED"},
+		{"tie, other order", `user.Age >= 18 || user.Verified == true`, map[string]interface{}{"Verified": false, "Age": 10}, "MINOR"},
+		{"no reasons", `user.Age < 18`, map[string]interface{}{"Age": 10}, ""},
+		// c_verified is true, but c_banned fails its group and c_age passes the rule
+		{"true operand of a failed group", `(user.Verified == true && user.Banned == false) || user.Age >= 18`,
+			map[string]interface{}{"Verified": true, "Banned": true, "Age": 20}, "ADULT"},
+		{"failed group", `(user.Verified == true && user.Banned == false) || user.Age >= 18`,
+			map[string]interface{}{"Verified": true, "Banned": true, "Age": 10}, "BANNED"},
+		{"negated atom", `!(user.Banned == false)`, map[string]interface{}{"Banned": true}, "BANNED"},
+		{"negated atom in and", `user.Age >= 18 && !(user.Banned == false)`, map[string]interface{}{"Age": 20, "Banned": false}, "NOT_BANNED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := New(map[string]interface{}{"user": tt.user}, WithMode(TraceAtomic)).Trace(tt.rule, metadataSpecs())
+			if got := res.PrimaryReason(); got != tt.want {
+				t.Fatalf("PrimaryReason = %q, want %q (chunks %+v)", got, tt.want, res.Chunks)
+			}
+		})
+	}
+}
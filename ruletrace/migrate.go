@@ -0,0 +1,62 @@
+package ruletrace
+
+import (
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/format"
+)
+
+// MigrateSpecs re-keys specs stored under fingerprints from releases that formatted
+// expressions differently: without the parentheses an operator's operands need, with
+// non-identifier member keys as `.key`, with constant int sets as Go maps and with
+// integral floats as ints (see the design notes in the README). Each sub-expression of
+// rule whose old fingerprint differs from its current one takes over the spec found under
+// the old fingerprint, unless specs already holds one under the current fingerprint.
+//
+// env is the env the rule is traced against, or nil: which constant sets the compiler
+// folds depends on its types. specs itself is not modified.
+func MigrateSpecs(rule string, specs map[string]ConditionSpec, env map[string]interface{}) (map[string]ConditionSpec, error) {
+	tree, err := parser.Parse(rule)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	roots := []ast.Node{tree.Node}
+	opts := []expr.Option{expr.AllowUndefinedVariables()}
+	if env != nil {
+		opts = append(opts, expr.Env(env))
+	}
+	// the compiled tree holds the folded constants traces format
+	if prog, err := expr.Compile(rule, opts...); err == nil {
+		roots = append(roots, prog.Node())
+	}
+
+	out := make(map[string]ConditionSpec, len(specs))
+	for fp, s := range specs {
+		out[fp] = s
+	}
+	cur, legacy := format.New(), format.NewLegacy()
+	moved := map[string]bool{}
+	for _, root := range roots {
+		ast.Find(root, func(n ast.Node) bool {
+			old, now := Fingerprint(legacy.Format(n)), Fingerprint(cur.Format(n))
+			if old == now {
+				return false
+			}
+			if s, ok := specs[old]; ok {
+				if _, taken := out[now]; !taken {
+					out[now] = s
+					moved[old] = true
+				}
+			}
+			return false
+		})
+	}
+	for old := range moved {
+		delete(out, old)
+	}
+	return out, nil
+}
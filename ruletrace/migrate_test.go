@@ -0,0 +1,85 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func migrateEnv() map[string]interface{} {
+	return map[string]interface{}{
+		"user": map[string]interface{}{"Age": 20, "Group": "admin", "Name": "bob", "Tags": []string{"a"}, "first name": "b"},
+		"x":    3,
+	}
+}
+
+// TestFingerprintStable pins chunk fingerprints to the values earlier releases computed,
+// so spec maps stored by them keep matching.
+func TestFingerprintStable(t *testing.T) {
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{`user.Age >= 18`, []string{"a8622d70d18f85008769b23e0927ec39"}},
+		{`user.Group in ["admin", "moderator"]`, []string{"f652c9dcebfa08a282b6234868eea62f"}},
+		{`user.Name contains "o" && len(user.Tags) > 0`, []string{"b852a871082734a2b8ced25de773deef", "5253033d689eb80679d9c7f4edc20485"}},
+		{`x > 1 && (user.Age > 30 || user.Name == "bob")`, []string{"a0a9fc927f12dd17e6b3d783498e4f94", "7ff9c65286fc2021681362ea912731ae", "487d4beb87c7837a6c9e7c3742f62ac6"}},
+		{`!(x > 5)`, []string{"06a6504a06b7c959676599e7b000cc50"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			var got []string
+			for _, c := range New(migrateEnv(), WithMode(TraceAtomic)).Trace(tt.rule, nil).Chunks {
+				got = append(got, c.Fingerprint)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("fingerprints = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMigrateSpecs keys specs by the fingerprints earlier releases gave atoms whose
+// formatting has since changed.
+func TestMigrateSpecs(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		legacy string // fingerprint of the atom's old formatting
+	}{
+		{"member key", `user["first name"] == "b"`, "8c51bbc8267e0878d28872f04332c4ae"}, // user.first name == "b"
+		{"int set", `x in [10, 2, 3]`, "625397c28d556d60909f1cea40b14042"},              // x in map[2:{} 3:{} 10:{}]
+		{"grouping", `(x + 1) * 2 > 7`, "ff823ef6f23e4b1f1f34b626cd5ae3ec"},             // x + 1 * 2 > 7
+		{"float literal", `x > 1.0`, "a0a9fc927f12dd17e6b3d783498e4f94"},                // x > 1
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			age := ConditionSpec{ID: "c_age", ReasonTrue: "ADULT", ReasonFalse: "MINOR"}
+			specs := map[string]ConditionSpec{
+				tt.legacy:                     {ID: "c_atom", ReasonTrue: "YES", ReasonFalse: "NO"},
+				Fingerprint(`user.Age >= 18`): age,
+			}
+			tracer := New(migrateEnv(), WithMode(TraceAtomic))
+			if c := tracer.Trace(tt.rule, specs).Chunks[0]; c.ID != "" {
+				t.Fatalf("legacy fingerprint still matches: %+v", c)
+			}
+
+			migrated, err := MigrateSpecs(tt.rule, specs, migrateEnv())
+			if err != nil {
+				t.Fatalf("MigrateSpecs: %v", err)
+			}
+			if _, ok := migrated[tt.legacy]; ok || len(migrated) != 2 || migrated[Fingerprint(`user.Age >= 18`)].ID != "c_age" {
+				t.Fatalf("migrated = %+v", migrated)
+			}
+			if c := tracer.Trace(tt.rule, migrated).Chunks[0]; c.ID != "c_atom" || c.Reason != "YES" {
+				t.Fatalf("chunk after migration = %+v", c)
+			}
+			if len(specs) != 2 || specs[tt.legacy].ID != "c_atom" {
+				t.Errorf("specs modified: %+v", specs)
+			}
+		})
+	}
+
+	if _, err := MigrateSpecs(`x >`, nil, nil); err == nil {
+		t.Error("MigrateSpecs of an invalid rule: want an error")
+	}
+}
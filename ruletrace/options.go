@@ -16,3 +16,48 @@ func WithShortCircuit(enabled bool) Option {
 
 // WithCond enables/disables semantic IDs + reasons support.
 func WithCond(enabled bool) Option { return optFunc(func(t *Tracer) { t.enableCond = enabled }) }
+
+// WithUnknownPolicy sets what an unknown Cond3 outcome returns to the rule (default UnknownAsFalse).
+func WithUnknownPolicy(p UnknownPolicy) Option {
+	return optFunc(func(t *Tracer) { t.unknown = p })
+}
+
+// WithCatalog renders chunk messages from cat in locale, keyed by reason code.
+// Reasons missing from the catalog fall back to the spec's message templates.
+func WithCatalog(cat Catalog, locale string) Option {
+	return optFunc(func(t *Tracer) { t.catalog, t.locale = cat, locale })
+}
+
+// WithNodeValues captures the value and source position of every sub-expression
+// (TraceResult.Values), as needed by TraceResult.PowerAssert. It costs one evaluation per node.
+func WithNodeValues(enabled bool) Option {
+	return optFunc(func(t *Tracer) { t.nodeValues = enabled })
+}
+
+// WithInputs records every env path the final evaluation dereferences, with the value
+// it read, into TraceResult.Inputs (e.g. "user.Group", "tweets[0].Len"), so the trace
+// can be replayed later without the full env (see Replay). Short-circuited branches
+// read nothing and record nothing.
+func WithInputs(enabled bool) Option {
+	return optFunc(func(t *Tracer) { t.inputs = enabled })
+}
+
+// allowUndefined lets the rule reference names missing from the env; they evaluate to nil.
+func allowUndefined() Option { return optFunc(func(t *Tracer) { t.undefined = true }) }
+
+// WithLibrary resolves references to the library's named conditions, as identifiers
+// (`is_staff`) or calls (`is_staff(user)`). Each reference is traced as one chunk under
+// the condition's spec ID, with the condition's own trace in EvalResult.Sub.
+func WithLibrary(lib *Library) Option { return optFunc(func(t *Tracer) { t.library = lib }) }
+
+// WithRules makes Rule("name") available in expressions, resolved against reg. Each
+// referenced rule is traced at most once per evaluation, and its trace is attached to
+// the calling chunk as EvalResult.Sub.
+func WithRules(reg *Registry) Option { return optFunc(func(t *Tracer) { t.rules = reg }) }
+
+// WithResolver reads names missing from the env through r, the first time an
+// identifier or member path is evaluated: r receives the longest static path read
+// (`comment.UserId`, or `tweets` for `tweets[i]`). Short-circuited branches resolve
+// nothing. Results are cached for the evaluation and recorded in TraceResult.Resolved;
+// errors are not cached, so each evaluation reading a failed path asks r again.
+func WithResolver(r EnvResolver) Option { return optFunc(func(t *Tracer) { t.resolver = r }) }
@@ -0,0 +1,233 @@
+package ruletrace
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/format"
+)
+
+// PartialResult is the outcome of evaluating a rule while some env paths are unknown.
+type PartialResult struct {
+	Decided  bool         // the outcome is the same whatever the unknown paths hold
+	Final    interface{}  // the outcome, when Decided
+	Relevant []string     // unknown paths the outcome still depends on, when not Decided
+	Chunks   []EvalResult // the rule's operands: evaluated, Unknown or Skipped
+}
+
+// Partial evaluates rule against env with the unknown paths (e.g. "user.Age", or "cart"
+// for everything under it) left open. Operands reading an unknown path are not evaluated;
+// `||`, `&&`, `not`, `??` and `?:` combine the rest with three-valued (Kleene) logic, so
+// `false && unknown` is decided false and `true || unknown` decided true.
+//
+// The logic is sound but not complete: `x || not x` is reported undecided. Operands are
+// split at those operators only, so an operand mixing known and unknown paths
+// (`user.Age > limit`) is unknown as a whole. opts apply to evaluating known operands.
+func Partial(rule string, specs map[string]ConditionSpec, env map[string]interface{}, unknown []string, opts ...Option) (PartialResult, error) {
+	tree, err := parser.Parse(rule)
+	if err != nil {
+		return PartialResult{}, fmt.Errorf("partial: %w", err)
+	}
+	p := &partial{
+		t:       New(env, append(opts, WithMode(TraceCoarse), WithCond(false))...),
+		specs:   specs,
+		unknown: unknown,
+		fmter:   format.New(),
+	}
+	p.deps = newDeps(p.t.library, p.t.registryRules())
+	v := p.eval(tree.Node)
+
+	res := PartialResult{Decided: !v.unknown, Chunks: p.chunks}
+	if v.unknown {
+		res.Relevant = sortedKeys(v.deps)
+	} else {
+		res.Final = v.val
+	}
+	return res, nil
+}
+
+// pvalue is a three-valued result: a known value, or unknown depending on deps.
+type pvalue struct {
+	val     interface{}
+	unknown bool
+	deps    map[string]bool // unknown paths
+}
+
+func known(v interface{}) pvalue { return pvalue{val: v} }
+
+func unknownOf(vs ...pvalue) pvalue {
+	deps := map[string]bool{}
+	for _, v := range vs {
+		for d := range v.deps {
+			deps[d] = true
+		}
+	}
+	return pvalue{unknown: true, deps: deps}
+}
+
+type partial struct {
+	t       *Tracer
+	specs   map[string]ConditionSpec
+	unknown []string
+	deps    *deps
+	fmter   *format.Formatter
+	chunks  []EvalResult
+}
+
+func (p *partial) eval(n ast.Node) pvalue {
+	switch n := n.(type) {
+	case *ast.ChainNode:
+		return p.eval(n.Node)
+
+	case *ast.UnaryNode:
+		if n.Operator != "not" && n.Operator != "!" {
+			break
+		}
+		v := p.eval(n.Node)
+		if v.unknown {
+			return v
+		}
+		if b, ok := v.val.(bool); ok {
+			return known(!b)
+		}
+		return known(nil)
+
+	case *ast.BinaryNode:
+		switch n.Operator {
+		case "||", "or":
+			return p.logical(n, true)
+		case "&&", "and":
+			return p.logical(n, false)
+		case "??":
+			left := p.eval(n.Left)
+			if !left.unknown && left.val != nil {
+				p.skip(n.Right)
+				return left
+			}
+			right := p.eval(n.Right)
+			if left.unknown {
+				return unknownOf(left, right)
+			}
+			return right
+		}
+
+	case *ast.ConditionalNode:
+		c := p.eval(n.Cond)
+		if !c.unknown {
+			b, ok := c.val.(bool)
+			switch {
+			case !ok:
+				p.skip(n.Exp1)
+				p.skip(n.Exp2)
+				return known(nil)
+			case b:
+				p.skip(n.Exp2)
+				return p.eval(n.Exp1)
+			default:
+				p.skip(n.Exp1)
+				return p.eval(n.Exp2)
+			}
+		}
+		a, b := p.eval(n.Exp1), p.eval(n.Exp2)
+		if !a.unknown && !b.unknown && reflect.DeepEqual(a.val, b.val) {
+			return a
+		}
+		return unknownOf(c, a, b)
+	}
+	return p.operand(n)
+}
+
+// logical combines `||` (or) and `&&` with Kleene logic: the absorbing value (true for
+// `||`, false for `&&`) decides the result even when the other side is unknown.
+func (p *partial) logical(n *ast.BinaryNode, or bool) pvalue {
+	left := p.eval(n.Left)
+	if !left.unknown {
+		b, ok := left.val.(bool)
+		if !ok || b == or {
+			p.skip(n.Right)
+			if !ok {
+				return known(nil)
+			}
+			return left
+		}
+		return p.eval(n.Right)
+	}
+	right := p.eval(n.Right)
+	if b, ok := right.val.(bool); !right.unknown && ok && b == or {
+		return right
+	}
+	if !right.unknown {
+		return unknownOf(left) // the known side cannot decide; the unknown one still can
+	}
+	return unknownOf(left, right)
+}
+
+// operand evaluates a node that is not a logical operator, unless it reads an unknown path.
+func (p *partial) operand(n ast.Node) pvalue {
+	src := p.fmter.Format(n)
+	r := EvalResult{Fingerprint: Fingerprint(src), Expr: src}
+	spec, hasSpec := p.specs[r.Fingerprint]
+	if hasSpec {
+		r.ID = spec.ID
+		applySpec(&r, spec)
+	}
+
+	deps := map[string]bool{}
+	for _, path := range p.deps.atomPaths(src, nil) {
+		for _, u := range p.unknown {
+			if overlapsAny(path, []string{u}) {
+				deps[u] = true
+			}
+		}
+	}
+	if len(deps) > 0 {
+		r.Unknown = true
+		if hasSpec {
+			r.Reason = spec.ReasonUnknown
+		}
+		p.chunks = append(p.chunks, r)
+		return pvalue{unknown: true, deps: deps}
+	}
+
+	res := p.t.Trace(src, nil)
+	r.Value = res.Final
+	if len(res.Chunks) > 0 {
+		r.Error = res.Chunks[0].Error
+	}
+	if b, ok := r.Value.(bool); ok && hasSpec {
+		r.Reason = spec.ReasonFalse
+		if b {
+			r.Reason = spec.ReasonTrue
+		}
+	}
+	p.chunks = append(p.chunks, r)
+	return known(r.Value)
+}
+
+// skip records the operands under n as short-circuited.
+func (p *partial) skip(n ast.Node) {
+	for _, r := range p.t.markSkipped(n, p.fmter) {
+		if s, ok := p.specs[r.Fingerprint]; ok {
+			r.ID = s.ID
+			applySpec(&r, s)
+		}
+		p.chunks = append(p.chunks, r)
+	}
+}
+
+// registryRules lists the rules Rule(...) references resolve to, if any.
+func (t *Tracer) registryRules() []Rule {
+	if t.rules == nil {
+		return nil
+	}
+	out := make([]Rule, 0, len(t.rules.rules))
+	for _, r := range t.rules.rules {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,97 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPartial(t *testing.T) {
+	env := map[string]interface{}{
+		"user": map[string]interface{}{"Group": "admin", "Banned": false},
+		"cart": map[string]interface{}{"Total": 50},
+	}
+	tests := []struct {
+		name     string
+		rule     string
+		unknown  []string
+		decided  bool
+		final    interface{}
+		relevant []string
+	}{
+		{"nothing unknown", `user.Group == "admin"`, nil, true, true, nil},
+		{"unknown operand", `user.Age >= 18`, []string{"user.Age"}, false, nil, []string{"user.Age"}},
+		{"true or unknown", `user.Group == "admin" || user.Age >= 18`, []string{"user.Age"}, true, true, nil},
+		{"unknown or true", `user.Age >= 18 || user.Group == "admin"`, []string{"user.Age"}, true, true, nil},
+		{"false and unknown", `user.Banned && user.Age >= 18`, []string{"user.Age"}, true, false, nil},
+		{"unknown and false", `user.Age >= 18 && user.Banned`, []string{"user.Age"}, true, false, nil},
+		{"unknown and true", `user.Age >= 18 && !user.Banned`, []string{"user.Age"}, false, nil, []string{"user.Age"}},
+		{"not unknown", `not (user.Age >= 18)`, []string{"user.Age"}, false, nil, []string{"user.Age"}},
+		{"two unknowns", `user.Age >= 18 || cart.Total > 100`, []string{"user.Age", "cart"}, false, nil, []string{"cart", "user.Age"}},
+		{"parent path unknown", `cart.Total > 10`, []string{"cart"}, false, nil, []string{"cart"}},
+		{"child path unknown", `len(cart) > 0`, []string{"cart.Total"}, false, nil, []string{"cart.Total"}},
+		{"unrelated unknown", `cart.Total > 10`, []string{"user"}, true, true, nil},
+		{"coalesce known left", `user.Group ?? user.Age`, []string{"user.Age"}, true, "admin", nil},
+		{"ternary known condition", `user.Banned ? user.Age : cart.Total`, []string{"user.Age"}, true, 50, nil},
+		{"ternary equal branches", `user.Age > 1 ? 1 : 1`, []string{"user.Age"}, true, 1, nil},
+		{"ternary unknown condition", `user.Age > 1 ? 1 : 2`, []string{"user.Age"}, false, nil, []string{"user.Age"}},
+		{"sound, not complete", `user.Age > 1 || not (user.Age > 1)`, []string{"user.Age"}, false, nil, []string{"user.Age"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Partial(tt.rule, nil, env, tt.unknown)
+			if err != nil {
+				t.Fatalf("Partial: %v", err)
+			}
+			if res.Decided != tt.decided || !reflect.DeepEqual(res.Final, tt.final) || !reflect.DeepEqual(res.Relevant, tt.relevant) {
+				t.Fatalf("Decided %v Final %v Relevant %v, want %v %v %v", res.Decided, res.Final, res.Relevant, tt.decided, tt.final, tt.relevant)
+			}
+		})
+	}
+}
+
+func TestPartialChunks(t *testing.T) {
+	specs := map[string]ConditionSpec{
+		Fingerprint(`user.Age >= 18`):        {ID: "c_age", ReasonTrue: "ADULT", ReasonFalse: "MINOR", ReasonUnknown: "AGE_UNKNOWN"},
+		Fingerprint(`user.Group == "admin"`): {ID: "c_group", ReasonTrue: "ADMIN", ReasonFalse: "NOT_ADMIN"},
+		Fingerprint(`user.Staff`):            {ID: "c_staff"},
+	}
+	env := map[string]interface{}{"user": map[string]interface{}{"Group": "guest"}}
+	res, err := Partial(`user.Age >= 18 && user.Group == "admin" || user.Staff`, specs, env, []string{"user.Age", "user.Staff"})
+	if err != nil {
+		t.Fatalf("Partial: %v", err)
+	}
+	if res.Decided || !reflect.DeepEqual(res.Relevant, []string{"user.Staff"}) {
+		t.Errorf("Decided %v Relevant %v, want [user.Staff]: user.Age no longer matters", res.Decided, res.Relevant)
+	}
+	want := []struct {
+		id, reason string
+		unknown    bool
+	}{
+		{"c_age", "AGE_UNKNOWN", true},
+		{"c_group", "NOT_ADMIN", false},
+		{"c_staff", "", true},
+	}
+	if len(res.Chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %+v", len(res.Chunks), len(want), res.Chunks)
+	}
+	for i, w := range want {
+		c := res.Chunks[i]
+		if c.ID != w.id || c.Reason != w.reason || c.Unknown != w.unknown {
+			t.Errorf("chunk %d = %+v, want ID %s reason %q unknown %v", i, c, w.id, w.reason, w.unknown)
+		}
+	}
+
+	res, err = Partial(`user.Group == "guest" || user.Age >= 18`, specs, env, []string{"user.Age"})
+	if err != nil {
+		t.Fatalf("Partial: %v", err)
+	}
+	if last := res.Chunks[len(res.Chunks)-1]; !last.Skipped || last.ID != "c_age" {
+		t.Errorf("short-circuited operand = %+v, want skipped c_age", last)
+	}
+}
+
+func TestPartialError(t *testing.T) {
+	if _, err := Partial(`a &&`, nil, nil, nil); err == nil {
+		t.Fatal("Partial of an invalid rule: want an error")
+	}
+}
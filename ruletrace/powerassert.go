@@ -0,0 +1,158 @@
+package ruletrace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+// PowerAssert renders the input with the value of every captured sub-expression aligned
+// underneath, Groovy/Spock style:
+//
+//	len(tweets) > 1
+//	|           |
+//	3           true
+//
+// It needs a trace made with WithNodeValues(true); otherwise only the input is returned.
+func (r TraceResult) PowerAssert() string { return r.powerAssert(false) }
+
+// PowerAssertANSI is PowerAssert with true values in green and false values and errors in red.
+func (r TraceResult) PowerAssertANSI() string { return r.powerAssert(true) }
+
+type placed struct {
+	col  int
+	text string
+	row  int
+	tint string
+}
+
+func (r TraceResult) powerAssert(color bool) string {
+	src := []rune(strings.NewReplacer("\n", " ", "\t", " ", "\r", " ").Replace(r.Input))
+
+	// One value per column: the outermost node wins (it is recorded last).
+	byCol := map[int]NodeValue{}
+	for _, v := range r.Values {
+		if v.Pos >= 0 && v.Pos <= len(src) {
+			byCol[v.Pos] = v
+		}
+	}
+	if len(byCol) == 0 {
+		return string(src)
+	}
+	vals := make([]placed, 0, len(byCol))
+	for col, v := range byCol {
+		text, tint := valueText(v)
+		vals = append(vals, placed{col: col, text: text, tint: tint})
+	}
+	// Right to left, so each value only has to avoid bars of values placed before it.
+	sort.Slice(vals, func(i, j int) bool { return vals[i].col > vals[j].col })
+
+	var rows [][]rune
+	for i := range vals {
+		v := &vals[i]
+		for row := 0; ; row++ {
+			if fits(vals[:i], v, row) {
+				v.row = row
+				break
+			}
+		}
+		for len(rows) <= v.row {
+			rows = append(rows, nil)
+		}
+	}
+
+	grid := make([][]rune, len(rows)+1)
+	width := len(src)
+	for _, v := range vals {
+		if end := v.col + len([]rune(v.text)); end > width {
+			width = end
+		}
+	}
+	for i := range grid {
+		grid[i] = []rune(strings.Repeat(" ", width))
+	}
+	tints := make([]map[int]placed, len(grid))
+	for i := range tints {
+		tints[i] = map[int]placed{}
+	}
+	for _, v := range vals {
+		for row := 0; row <= v.row; row++ {
+			grid[row][v.col] = '|'
+		}
+		copy(grid[v.row+1][v.col:], []rune(v.text))
+		tints[v.row+1][v.col] = v
+	}
+
+	var sb strings.Builder
+	sb.WriteString(string(src))
+	for i, line := range grid {
+		sb.WriteString("\n")
+		if !color {
+			sb.WriteString(strings.TrimRight(string(line), " "))
+			continue
+		}
+		sb.WriteString(colorize(line, tints[i]))
+	}
+	return sb.String()
+}
+
+// fits reports whether v can sit on row: its text must not overlap values already on that
+// row, nor cross the bars of values placed further down.
+func fits(done []placed, v *placed, row int) bool {
+	end := v.col + len([]rune(v.text))
+	for _, p := range done {
+		pend := p.col + len([]rune(p.text))
+		if p.row == row && v.col <= pend && p.col <= end {
+			return false
+		}
+		if p.row > row && p.col >= v.col && p.col <= end {
+			return false
+		}
+	}
+	return true
+}
+
+func colorize(line []rune, tints map[int]placed) string {
+	var sb strings.Builder
+	s := strings.TrimRight(string(line), " ")
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		p, ok := tints[i]
+		if !ok || p.tint == "" {
+			sb.WriteRune(rs[i])
+			continue
+		}
+		n := len([]rune(p.text))
+		sb.WriteString(p.tint)
+		sb.WriteString(string(rs[i : i+n]))
+		sb.WriteString(ansiReset)
+		i += n - 1
+	}
+	return sb.String()
+}
+
+func valueText(v NodeValue) (string, string) {
+	if v.Error != "" {
+		msg, _, _ := strings.Cut(v.Error, "\n")
+		return "error: " + msg, ansiRed
+	}
+	switch x := v.Value.(type) {
+	case nil:
+		return "nil", ""
+	case bool:
+		if x {
+			return "true", ansiGreen
+		}
+		return "false", ansiRed
+	case string:
+		return fmt.Sprintf("%q", x), ""
+	default:
+		return fmt.Sprintf("%v", x), ""
+	}
+}
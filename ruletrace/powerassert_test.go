@@ -0,0 +1,80 @@
+package ruletrace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPowerAssert(t *testing.T) {
+	env := map[string]interface{}{
+		"tweets": []string{"a", "b", "c"},
+		"user":   map[string]interface{}{"Age": 17, "Name": "bob"},
+	}
+	tests := []struct {
+		name string
+		rule string
+		want []string
+	}{
+		{"builtin", `len(tweets) > 1`, []string{
+			`len(tweets) > 1`,
+			`|   |       |`,
+			`3   [a b c] true`,
+		}},
+		{"short-circuit skips the right side", `user.Age >= 18 && user.Name == "bob"`, []string{
+			`user.Age >= 18 && user.Name == "bob"`,
+			`|    |   |     |`,
+			`|    17  false false`,
+			`map[Age:17 Name:bob]`,
+		}},
+		{"overlapping values stack", `user.Age >= 18 || user.Name == "bob"`, []string{
+			`user.Age >= 18 || user.Name == "bob"`,
+			`|    |   |     |  |    |    |`,
+			`|    17  false |  |    |    true`,
+			`|              |  |    "bob"`,
+			`|              |  map[Age:17 Name:bob]`,
+			`|              true`,
+			`map[Age:17 Name:bob]`,
+		}},
+		{"error", `user.Name > 18`, []string{
+			`user.Name > 18`,
+			`|    |    |`,
+			`|    |    error: invalid operation: string > int (1:11)`,
+			`|    "bob"`,
+			`map[Age:17 Name:bob]`,
+		}},
+		{"newlines become spaces", "len(tweets)\n> 1", []string{
+			`len(tweets) > 1`,
+			`|   |       |`,
+			`3   [a b c] true`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := New(env, WithNodeValues(true)).Trace(tt.rule, nil)
+			if got, want := res.PowerAssert(), strings.Join(tt.want, "\n"); got != want {
+				t.Fatalf("PowerAssert =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestPowerAssertANSI(t *testing.T) {
+	env := map[string]interface{}{"user": map[string]interface{}{"Age": 17}}
+	got := New(env, WithNodeValues(true)).Trace(`user.Age >= 18`, nil).PowerAssertANSI()
+	want := strings.Join([]string{
+		`user.Age >= 18`,
+		`|    |   |`,
+		"|    17  " + ansiRed + "false" + ansiReset,
+		`map[Age:17]`,
+	}, "\n")
+	if got != want {
+		t.Fatalf("PowerAssertANSI = %q, want %q", got, want)
+	}
+}
+
+func TestPowerAssertWithoutValues(t *testing.T) {
+	res := New(map[string]interface{}{"x": 2}).Trace(`x > 1`, nil)
+	if got := res.PowerAssert(); got != `x > 1` {
+		t.Fatalf("PowerAssert = %q, want the input alone", got)
+	}
+}
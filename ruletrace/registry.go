@@ -0,0 +1,151 @@
+package ruletrace
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/patch"
+)
+
+// Registry resolves Rule("name") references between rules.
+type Registry struct {
+	rules map[string]Rule
+}
+
+// NewRegistry validates rules and their references: each Rule(...) call must take a
+// string literal naming a registered rule, and references must not form a cycle.
+func NewRegistry(rules ...Rule) (*Registry, error) {
+	return newRegistry(rules, true)
+}
+
+// newRegistry builds a registry; unless strict, references to rules outside of rules
+// are left for the registry used at trace time to resolve.
+func newRegistry(rules []Rule, strict bool) (*Registry, error) {
+	reg := &Registry{rules: make(map[string]Rule, len(rules))}
+	for _, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("registry: rule with empty name")
+		}
+		if _, dup := reg.rules[r.Name]; dup {
+			return nil, fmt.Errorf("registry: duplicate rule %q", r.Name)
+		}
+		reg.rules[r.Name] = r
+	}
+
+	refs := make(map[string][]string, len(rules))
+	for _, r := range rules {
+		names, err := ruleRefs(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("registry: rule %q: %w", r.Name, err)
+		}
+		for _, n := range names {
+			if _, ok := reg.rules[n]; !ok && strict {
+				return nil, fmt.Errorf("registry: rule %q references unknown rule %q", r.Name, n)
+			}
+		}
+		refs[r.Name] = names
+	}
+	if cycle := findCycle(refs); cycle != nil {
+		return nil, fmt.Errorf("registry: reference cycle %s", strings.Join(cycle, " -> "))
+	}
+	return reg, nil
+}
+
+// ruleRefs returns the names referenced by Rule(...) calls in src.
+func ruleRefs(src string) ([]string, error) {
+	tree, err := parser.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		names  []string
+		badArg bool
+	)
+	ast.Find(tree.Node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallNode)
+		if !ok {
+			return false
+		}
+		if id, ok := call.Callee.(*ast.IdentifierNode); !ok || id.Value != "Rule" {
+			return false
+		}
+		if name, ok := patch.RuleCallName(call); ok {
+			names = append(names, name)
+		} else {
+			badArg = true
+		}
+		return false
+	})
+	if badArg {
+		return nil, fmt.Errorf("Rule expects a single string literal")
+	}
+	return names, nil
+}
+
+// Lookup returns the rule registered under name.
+func (r *Registry) Lookup(name string) (Rule, bool) {
+	rule, ok := r.rules[name]
+	return rule, ok
+}
+
+// ruleMemo holds the traces of referenced rules for one evaluation, so each rule runs
+// at most once however many rules (or chunks) reference it.
+type ruleMemo struct {
+	traces map[string]*TraceResult
+}
+
+// ruleFunc implements Rule(name): it traces the named rule against the tracer's env, or
+// reuses its memoized trace, and returns its Final.
+func (t *Tracer) ruleFunc(lc *libraryCalls) func(params ...any) (any, error) {
+	return func(params ...any) (any, error) {
+		name, ok := "", len(params) == 1
+		if ok {
+			name, ok = params[0].(string)
+		}
+		if !ok {
+			return nil, fmt.Errorf("Rule expects a single string argument")
+		}
+		r, ok := t.rules.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("Rule: unknown rule %q", name)
+		}
+		res := t.traceRule(r)
+		for path, v := range res.Inputs {
+			lc.inputs[path] = v
+		}
+		return boolFinal("rule "+name, *res, false)
+	}
+}
+
+// traceRule traces r, memoized per evaluation.
+func (t *Tracer) traceRule(r Rule) *TraceResult {
+	if res, ok := t.memo.traces[r.Name]; ok {
+		return res
+	}
+	res := t.Trace(r.Expr, r.Specs)
+	t.memo.traces[r.Name] = &res
+	return &res
+}
+
+func newRuleMemo() *ruleMemo { return &ruleMemo{traces: map[string]*TraceResult{}} }
+
+// boolFinal returns a sub-trace's Final to the calling expression. A result that is not
+// a bool becomes nil when allowNil (for Cond3 to record as unknown), else an error
+// carrying the sub-trace's first chunk error.
+func boolFinal(what string, res TraceResult, allowNil bool) (any, error) {
+	if b, ok := res.Final.(bool); ok {
+		return b, nil
+	}
+	if allowNil {
+		return nil, nil
+	}
+	for _, ch := range res.Chunks {
+		if ch.Error != "" {
+			return nil, fmt.Errorf("%s: %s", what, ch.Error)
+		}
+	}
+	return nil, fmt.Errorf("%s: result is %T, not bool", what, res.Final)
+}
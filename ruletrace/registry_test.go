@@ -0,0 +1,121 @@
+package ruletrace
+
+import (
+	"strings"
+	"testing"
+)
+
+func docRegistry(t *testing.T) *Registry {
+	t.Helper()
+	reg, err := NewRegistry(
+		Rule{Name: "can_view", Expr: `doc.Public || user.Id == doc.OwnerId`},
+		Rule{Name: "can_edit", Expr: `Rule("can_view") && !doc.Locked`},
+	)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	return reg
+}
+
+func TestNewRegistryErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+	}{
+		{"unknown rule", []Rule{{Name: "a", Expr: `Rule("b")`}}},
+		{"non-literal argument", []Rule{{Name: "a", Expr: `Rule(name)`}, {Name: "b", Expr: `true`}}},
+		{"two arguments", []Rule{{Name: "a", Expr: `Rule("b", "c")`}, {Name: "b", Expr: `true`}}},
+		{"self reference", []Rule{{Name: "a", Expr: `x || Rule("a")`}}},
+		{"cycle", []Rule{{Name: "a", Expr: `Rule("b")`}, {Name: "b", Expr: `Rule("c")`}, {Name: "c", Expr: `Rule("a")`}}},
+		{"duplicate", []Rule{{Name: "a", Expr: `x`}, {Name: "a", Expr: `y`}}},
+		{"empty name", []Rule{{Expr: `x`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRegistry(tt.rules...); err == nil {
+				t.Fatal("NewRegistry: want an error")
+			}
+		})
+	}
+}
+
+func TestRuleReference(t *testing.T) {
+	user := map[string]interface{}{"Id": 1}
+	tests := []struct {
+		name  string
+		rule  string
+		doc   map[string]interface{}
+		final interface{}
+		ids   []string // chunk IDs; a "-" prefix marks a skipped chunk
+	}{
+		{"owner can edit", `Rule("can_edit")`, map[string]interface{}{"Public": false, "OwnerId": 1, "Locked": false}, true, []string{"can_edit"}},
+		{"locked", `Rule("can_edit")`, map[string]interface{}{"Public": true, "OwnerId": 2, "Locked": true}, false, []string{"can_edit"}},
+		{"short-circuited reference", `doc.Locked || Rule("can_view")`, map[string]interface{}{"Locked": true}, true, []string{"", "-can_view"}},
+		{"reference and body", `Rule("can_view") && Rule("can_edit")`, map[string]interface{}{"Public": true, "Locked": false}, true, []string{"can_view", "can_edit"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]interface{}{"user": user, "doc": tt.doc}
+			res := New(env, WithRules(docRegistry(t)), WithMode(TraceAtomic)).Trace(tt.rule, nil)
+			if res.Final != tt.final {
+				t.Fatalf("Final = %v, want %v (chunks %+v)", res.Final, tt.final, res.Chunks)
+			}
+			if len(res.Chunks) != len(tt.ids) {
+				t.Fatalf("got %d chunks, want %d: %+v", len(res.Chunks), len(tt.ids), res.Chunks)
+			}
+			for i, c := range res.Chunks {
+				id := c.ID
+				if c.Skipped {
+					id = "-" + id
+				}
+				if id != tt.ids[i] {
+					t.Errorf("chunk %d ID = %q, want %q", i, id, tt.ids[i])
+				}
+				if c.ID != "" && !c.Skipped && (c.Sub == nil || c.Sub.Final != c.Value) {
+					t.Errorf("chunk %s: Sub %+v does not match value %v", c.ID, c.Sub, c.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestRuleReferenceNested(t *testing.T) {
+	env := map[string]interface{}{
+		"user": map[string]interface{}{"Id": 1},
+		"doc":  map[string]interface{}{"Public": false, "OwnerId": 1, "Locked": false},
+	}
+	res := New(env, WithRules(docRegistry(t)), WithMode(TraceAtomic)).Trace(`Rule("can_edit") && Rule("can_view")`, nil)
+	edit, view := res.Chunks[0].Sub, res.Chunks[1].Sub
+	if edit == nil || view == nil {
+		t.Fatalf("missing sub-traces: %+v", res.Chunks)
+	}
+	// can_view ran once, inside can_edit: the top-level reference reuses that trace
+	if nested := edit.Chunks[0].Sub; nested != view {
+		t.Errorf("can_view traced twice: %p and %p", nested, view)
+	}
+	if view.Input != `doc.Public || user.Id == doc.OwnerId` {
+		t.Errorf("can_view sub-trace input = %q", view.Input)
+	}
+}
+
+func TestRuleReferenceErrors(t *testing.T) {
+	env := map[string]interface{}{"doc": map[string]interface{}{}}
+	tests := []struct {
+		name, rule, err string
+	}{
+		{"unknown at trace time", `Rule("nope")`, `unknown rule "nope"`},
+		{"not bool", `Rule("count")`, `rule count: result is int, not bool`},
+	}
+	reg, err := NewRegistry(Rule{Name: "count", Expr: `1 + 1`})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := New(env, WithRules(reg)).Trace(tt.rule, nil)
+			if res.Final != nil || len(res.Chunks) != 1 || !strings.Contains(res.Chunks[0].Error, tt.err) {
+				t.Fatalf("Final %v, chunks %+v, want an error containing %q", res.Final, res.Chunks, tt.err)
+			}
+		})
+	}
+}
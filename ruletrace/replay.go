@@ -0,0 +1,29 @@
+package ruletrace
+
+import (
+	"fmt"
+
+	"github.com/aqilarik/ruletrace/internal/access"
+)
+
+// Replay re-runs a stored trace against the current rule and specs, using the env
+// snapshot in stored.Inputs and the stored trace mode, and reports what changed.
+// opts are applied after the stored mode, so they can override it. Env functions are
+// not part of the snapshot, so rules calling them cannot be replayed.
+func Replay(stored TraceResult, rule string, specs map[string]ConditionSpec, opts ...Option) (TraceDiff, error) {
+	if stored.Inputs == nil {
+		return TraceDiff{}, fmt.Errorf("replay: stored trace has no inputs (trace it WithInputs(true))")
+	}
+	env, err := access.Expand(stored.Inputs)
+	if err != nil {
+		return TraceDiff{}, fmt.Errorf("replay: %w", err)
+	}
+	// Names the stored run never read (e.g. behind a short-circuit) are undefined in
+	// the snapshot; they evaluate to nil if the current rule reads them.
+	opts = append([]Option{WithMode(stored.Mode), WithInputs(true), allowUndefined()}, opts...)
+	current, err := New(env, opts...).TraceStrict(rule, specs)
+	if err != nil {
+		return TraceDiff{}, fmt.Errorf("replay: %w", err)
+	}
+	return Diff(stored, current), nil
+}
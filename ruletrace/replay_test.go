@@ -0,0 +1,67 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInputs(t *testing.T) {
+	type tweet struct{ Len int }
+	env := map[string]interface{}{
+		"user":    map[string]interface{}{"Group": "admin", "Id": 7, "Profile": nil, "Secret": "s3cret"},
+		"comment": map[string]interface{}{"UserId": 7},
+		"tweets":  []tweet{{Len: 120}, {Len: 300}},
+	}
+	tests := []struct {
+		name string
+		rule string
+		want map[string]interface{}
+	}{
+		{"short-circuit reads the left side only", `user.Group in ["admin", "moderator"] || user.Id == comment.UserId`,
+			map[string]interface{}{"user.Group": "admin"}},
+		{"both sides", `user.Group == "guest" || user.Id == comment.UserId`,
+			map[string]interface{}{"user.Group": "admin", "user.Id": 7, "comment.UserId": 7}},
+		{"index", `tweets[0].Len < 280`, map[string]interface{}{"tweets[0].Len": 120}},
+		{"nil parent in optional chain", `(user?.Profile?.Age ?? 0) >= 18`, map[string]interface{}{"user.Profile": nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := New(env, WithInputs(true)).Trace(tt.rule, nil)
+			if !reflect.DeepEqual(res.Inputs, tt.want) {
+				t.Errorf("Inputs = %v, want %v", res.Inputs, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	env := map[string]interface{}{"user": map[string]interface{}{"Group": "admin", "Age": 16, "Name": "ann"}}
+	stored := New(env, WithInputs(true), WithMode(TraceCoarse)).Trace(`user.Group == "admin" || user.Age >= 18`, nil)
+
+	tests := []struct {
+		name    string
+		rule    string
+		changed bool
+		final   bool
+	}{
+		{"same rule", `user.Group == "admin" || user.Age >= 18`, false, false},
+		{"rule now reads a recorded path", `user.Group == "admin" && user.Age >= 18`, true, true},
+		// user.Name was never read, so it is nil in the snapshot
+		{"rule reads an unrecorded path", `user.Group == "admin" && user.Name == "ann"`, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Replay(stored, tt.rule, nil)
+			if err != nil {
+				t.Fatalf("Replay: %v", err)
+			}
+			if d.Changed() != tt.changed || d.FinalChanged() != tt.final {
+				t.Errorf("Changed %v FinalChanged %v, want %v %v\n%s", d.Changed(), d.FinalChanged(), tt.changed, tt.final, d)
+			}
+		})
+	}
+
+	if _, err := Replay(New(env).Trace(`user.Age > 1`, nil), `user.Age > 1`, nil); err == nil {
+		t.Error("Replay of a trace without inputs succeeded")
+	}
+}
@@ -0,0 +1,297 @@
+package ruletrace
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/format"
+	"github.com/aqilarik/ruletrace/internal/patch"
+)
+
+// Residual is what is left of a rule once the known part of the env is folded in.
+type Residual struct {
+	Source  string                   // residual rule, canonical source
+	Specs   map[string]ConditionSpec // specs of the remaining atoms, keyed by their new fingerprints
+	Decided bool                     // the residual is a constant
+	Final   interface{}              // that constant, when Decided
+}
+
+// Residualize folds env into rule, leaving the parts that read the unknown paths: known
+// sub-expressions become literals (`cart.Total > user.Limit` becomes `cart.Total > 100`
+// with cart unknown), and `||`, `&&`, `not`, `??` and `?:` are simplified around known
+// operands (`true && x` becomes `x`, `false && x` becomes `false`).
+//
+// A known operand on the right decides the operator whatever x is, so `x || true` becomes
+// `true` and `x && false` becomes `false`, as expr's own optimizer folds them; `x && true`
+// and `x || false` become `x`. The residual can therefore accept inputs the rule rejects:
+// where x fails to evaluate or is not a boolean the rule errors, while the residual gives
+// its constant or x's value.
+//
+// Specs follow their atoms to their residual fingerprints; the specs of atoms folded
+// away are dropped. Known values that have no literal form (structs, functions) and
+// operands that fail to evaluate are left as written. opts apply to evaluating the known
+// parts.
+func Residualize(rule string, specs map[string]ConditionSpec, env map[string]interface{}, unknown []string, opts ...Option) (Residual, error) {
+	tree, err := parser.Parse(rule)
+	if err != nil {
+		return Residual{}, fmt.Errorf("residual: %w", err)
+	}
+	p := &partial{
+		t:       New(env, append(opts, WithMode(TraceCoarse), WithCond(false))...),
+		specs:   specs,
+		unknown: unknown,
+		fmter:   format.New(),
+	}
+	p.deps = newDeps(p.t.library, p.t.registryRules())
+	r := &residualizer{p: p, bound: letNames(tree.Node), specs: map[string]ConditionSpec{}}
+
+	root := tree.Node
+	r.simplify(&root)
+	out := Residual{Source: p.fmter.Format(root), Specs: r.specs}
+	if v, ok := literalValue(root); ok {
+		out.Decided, out.Final = true, v
+	}
+	return out, nil
+}
+
+type residualizer struct {
+	p     *partial
+	bound map[string]bool // let names, never folded on their own
+	specs map[string]ConditionSpec
+}
+
+// simplify rewrites the logical structure at *node and folds its operands.
+func (r *residualizer) simplify(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.ChainNode:
+		r.simplify(&n.Node)
+		if _, ok := literalValue(n.Node); ok {
+			*node = n.Node
+		}
+		return
+
+	case *ast.UnaryNode:
+		if n.Operator != "not" && n.Operator != "!" {
+			break
+		}
+		r.simplify(&n.Node)
+		if b, ok := literalBool(n.Node); ok {
+			*node = &ast.BoolNode{Value: !b}
+		}
+		return
+
+	case *ast.BinaryNode:
+		switch n.Operator {
+		case "||", "or", "&&", "and":
+			or := n.Operator == "||" || n.Operator == "or"
+			r.simplify(&n.Left)
+			if b, ok := literalBool(n.Left); ok {
+				if b == or {
+					*node = n.Left
+					return
+				}
+				r.simplify(&n.Right)
+				*node = n.Right
+				return
+			}
+			r.simplify(&n.Right)
+			if b, ok := literalBool(n.Right); ok {
+				if b == or {
+					*node = n.Right // `x || true` is true, whatever x
+				} else {
+					*node = n.Left
+				}
+			}
+			return
+		case "??":
+			r.simplify(&n.Left)
+			if v, ok := literalValue(n.Left); ok {
+				if v != nil {
+					*node = n.Left
+					return
+				}
+				r.simplify(&n.Right)
+				*node = n.Right
+				return
+			}
+			r.simplify(&n.Right)
+			return
+		}
+
+	case *ast.ConditionalNode:
+		r.simplify(&n.Cond)
+		if b, ok := literalBool(n.Cond); ok {
+			if b {
+				r.simplify(&n.Exp1)
+				*node = n.Exp1
+			} else {
+				r.simplify(&n.Exp2)
+				*node = n.Exp2
+			}
+			return
+		}
+		r.simplify(&n.Exp1)
+		r.simplify(&n.Exp2)
+		a, aok := literalValue(n.Exp1)
+		b, bok := literalValue(n.Exp2)
+		if aok && bok && reflect.DeepEqual(a, b) {
+			*node = n.Exp1
+		}
+		return
+	}
+	r.operand(node)
+}
+
+// operand folds an operand of the logical structure and carries the specs of the atoms
+// it keeps over to their new fingerprints.
+func (r *residualizer) operand(node *ast.Node) {
+	type atom struct {
+		node ast.Node
+		spec ConditionSpec
+	}
+	var atoms []atom
+	for _, a := range patch.CollectAtoms(*node) {
+		if s, ok := r.p.specs[Fingerprint(r.p.fmter.Format(a))]; ok {
+			atoms = append(atoms, atom{node: a, spec: s})
+		}
+	}
+
+	r.fold(node)
+
+	for _, a := range atoms {
+		if a.node == *node || ast.Find(*node, func(n ast.Node) bool { return n == a.node }) != nil {
+			r.specs[Fingerprint(r.p.fmter.Format(a.node))] = a.spec
+		}
+	}
+}
+
+// fold replaces *node by a literal when it reads no unknown path, or else folds its
+// children.
+func (r *residualizer) fold(node *ast.Node) {
+	switch (*node).(type) {
+	case *ast.NilNode, *ast.BoolNode, *ast.IntegerNode, *ast.FloatNode, *ast.StringNode:
+		return
+	}
+	if r.foldable(*node) {
+		res := r.p.t.Trace(r.p.fmter.Format(*node), nil)
+		failed := len(res.Chunks) > 0 && res.Chunks[0].Error != ""
+		if lit, ok := literal(res.Final); ok && !failed {
+			*node = lit
+			return
+		}
+	}
+	switch n := (*node).(type) {
+	case *ast.UnaryNode:
+		r.fold(&n.Node)
+	case *ast.BinaryNode:
+		r.fold(&n.Left)
+		r.fold(&n.Right)
+	case *ast.ConditionalNode:
+		r.fold(&n.Cond)
+		r.fold(&n.Exp1)
+		r.fold(&n.Exp2)
+	case *ast.ChainNode:
+		r.fold(&n.Node)
+	case *ast.MemberNode:
+		r.fold(&n.Property)
+	case *ast.CallNode:
+		for i := range n.Arguments {
+			r.fold(&n.Arguments[i])
+		}
+	case *ast.BuiltinNode:
+		for i := range n.Arguments {
+			r.fold(&n.Arguments[i])
+		}
+	case *ast.PredicateNode:
+		r.fold(&n.Node)
+	case *ast.ArrayNode:
+		for i := range n.Nodes {
+			r.fold(&n.Nodes[i])
+		}
+	}
+}
+
+// foldable reports whether n can be evaluated now: it reads no unknown path, closure
+// argument or let name.
+func (r *residualizer) foldable(n ast.Node) bool {
+	blocked := ast.Find(n, func(x ast.Node) bool {
+		switch x := x.(type) {
+		case *ast.PointerNode, *ast.VariableDeclaratorNode:
+			return true
+		case *ast.IdentifierNode:
+			return r.bound[x.Value]
+		}
+		return false
+	})
+	if blocked != nil {
+		return false
+	}
+	return !affected(r.p.deps.atomPaths(r.p.fmter.Format(n), nil), r.p.unknown)
+}
+
+// literal returns the source literal for v, if it has one: NaN, the infinities and
+// unsigned values beyond int have none.
+func literal(v interface{}) (ast.Node, bool) {
+	if v == nil {
+		return &ast.NilNode{}, true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return &ast.BoolNode{Value: rv.Bool()}, true
+	case reflect.String:
+		return &ast.StringNode{Value: rv.String()}, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &ast.IntegerNode{Value: int(rv.Int())}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt {
+			return nil, false
+		}
+		return &ast.IntegerNode{Value: int(rv.Uint())}, true
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+		return &ast.FloatNode{Value: rv.Float()}, true
+	case reflect.Slice, reflect.Array:
+		arr := &ast.ArrayNode{}
+		for i := 0; i < rv.Len(); i++ {
+			n, ok := literal(rv.Index(i).Interface())
+			if !ok {
+				return nil, false
+			}
+			arr.Nodes = append(arr.Nodes, n)
+		}
+		return arr, true
+	}
+	return nil, false
+}
+
+// literalValue returns the value of a scalar literal node.
+func literalValue(n ast.Node) (interface{}, bool) {
+	switch n := n.(type) {
+	case *ast.NilNode:
+		return nil, true
+	case *ast.BoolNode:
+		return n.Value, true
+	case *ast.IntegerNode:
+		return n.Value, true
+	case *ast.FloatNode:
+		return n.Value, true
+	case *ast.StringNode:
+		return n.Value, true
+	}
+	return nil, false
+}
+
+func literalBool(n ast.Node) (bool, bool) {
+	b, ok := n.(*ast.BoolNode)
+	if !ok {
+		return false, false
+	}
+	return b.Value, true
+}
@@ -0,0 +1,86 @@
+package ruletrace
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestResidualize(t *testing.T) {
+	env := map[string]interface{}{
+		"user":  map[string]interface{}{"Group": "admin", "Banned": false, "Limit": 100, "Max": 100.0, "Tags": []string{"a", "b"}},
+		"big":   uint64(math.MaxUint64),
+		"inf":   math.Inf(1),
+		"owner": struct{ Id int }{7},
+	}
+	tests := []struct {
+		name    string
+		rule    string
+		unknown []string
+		source  string
+		decided bool
+		final   interface{}
+	}{
+		{"known operand folded", `cart.Total > user.Limit`, []string{"cart"}, `cart.Total > 100`, false, nil},
+		{"float operand folded", `cart.Total > user.Max`, []string{"cart"}, `cart.Total > 100.0`, false, nil},
+		{"true and x", `user.Group == "admin" && cart.Total > 10`, []string{"cart"}, `cart.Total > 10`, false, nil},
+		{"false and x", `user.Banned && cart.Total > 10`, []string{"cart"}, `false`, true, false},
+		{"x or true", `cart.Total > 10 || user.Group == "admin"`, []string{"cart"}, `true`, true, true},
+		{"x and false", `cart.Total > 10 && user.Banned`, []string{"cart"}, `false`, true, false},
+		{"x or false", `cart.Total > 10 || user.Banned`, []string{"cart"}, `cart.Total > 10`, false, nil},
+		{"x and true", `cart.Total > 10 && !user.Banned`, []string{"cart"}, `cart.Total > 10`, false, nil},
+		{"not folded", `not user.Banned && cart.Empty`, []string{"cart"}, `cart.Empty`, false, nil},
+		{"coalesce", `user.Nick ?? cart.Name`, []string{"cart"}, `cart.Name`, false, nil},
+		{"ternary on known", `user.Banned ? cart.A : cart.B`, []string{"cart"}, `cart.B`, false, nil},
+		{"ternary kept", `cart.Open ? user.Limit : 0`, []string{"cart"}, `cart.Open ? 100 : 0`, false, nil},
+		{"list literal", `cart.Tag in user.Tags`, []string{"cart"}, `cart.Tag in ["a", "b"]`, false, nil},
+		{"nothing unknown", `user.Limit > 10`, nil, `true`, true, true},
+		{"everything unknown", `user.Limit > 10`, []string{"user"}, `user.Limit > 10`, false, nil},
+		{"closure not folded", `all(cart.Items, .Price < user.Limit)`, []string{"cart"}, `all(cart.Items, {#.Price < 100})`, false, nil},
+		{"struct left as written", `cart.Owner == owner`, []string{"cart"}, `cart.Owner == owner`, false, nil},
+		{"uint beyond int left as written", `cart.Total < big`, []string{"cart"}, `cart.Total < big`, false, nil},
+		{"infinity left as written", `cart.Total < inf`, []string{"cart"}, `cart.Total < inf`, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Residualize(tt.rule, nil, env, tt.unknown)
+			if err != nil {
+				t.Fatalf("Residualize: %v", err)
+			}
+			if res.Source != tt.source || res.Decided != tt.decided || !reflect.DeepEqual(res.Final, tt.final) {
+				t.Fatalf("Source %q Decided %v Final %v, want %q %v %v", res.Source, res.Decided, res.Final, tt.source, tt.decided, tt.final)
+			}
+		})
+	}
+}
+
+func TestResidualizeSpecs(t *testing.T) {
+	specs := map[string]ConditionSpec{
+		Fingerprint(`cart.Total > user.Limit`): {ID: "c_total", ReasonTrue: "OVER", ReasonFalse: "UNDER"},
+		Fingerprint(`user.Group == "admin"`):   {ID: "c_group"},
+		Fingerprint(`cart.Items > 0`):          {ID: "c_items"},
+	}
+	env := map[string]interface{}{"user": map[string]interface{}{"Group": "admin", "Limit": 100}}
+	res, err := Residualize(`user.Group == "admin" && cart.Total > user.Limit && cart.Items > 0`, specs, env, []string{"cart"})
+	if err != nil {
+		t.Fatalf("Residualize: %v", err)
+	}
+	if res.Source != `cart.Total > 100 && cart.Items > 0` {
+		t.Fatalf("Source = %q", res.Source)
+	}
+	want := map[string]ConditionSpec{
+		Fingerprint(`cart.Total > 100`): specs[Fingerprint(`cart.Total > user.Limit`)],
+		Fingerprint(`cart.Items > 0`):   specs[Fingerprint(`cart.Items > 0`)],
+	}
+	if !reflect.DeepEqual(res.Specs, want) {
+		t.Fatalf("Specs = %+v, want %+v", res.Specs, want)
+	}
+
+	// the residual traces with the carried specs like the rule did
+	cart := map[string]interface{}{"Total": 150, "Items": 2}
+	full := New(map[string]interface{}{"user": env["user"], "cart": cart}).Trace(`user.Group == "admin" && cart.Total > user.Limit && cart.Items > 0`, specs)
+	resid := New(map[string]interface{}{"cart": cart}).Trace(res.Source, res.Specs)
+	if full.Final != resid.Final || full.PrimaryReason() != resid.PrimaryReason() {
+		t.Errorf("residual trace %v %q, rule trace %v %q", resid.Final, resid.PrimaryReason(), full.Final, full.PrimaryReason())
+	}
+}
@@ -0,0 +1,121 @@
+package ruletrace
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/expr-lang/expr/ast"
+
+	"github.com/aqilarik/ruletrace/internal/access"
+)
+
+// EnvResolver supplies env values lazily, for names missing from the env (see
+// WithResolver). path uses the TraceResult.Inputs syntax, e.g. "comment.UserId".
+type EnvResolver interface {
+	Resolve(path string) (interface{}, error)
+}
+
+// ResolverFunc adapts a function to EnvResolver.
+type ResolverFunc func(path string) (interface{}, error)
+
+func (f ResolverFunc) Resolve(path string) (interface{}, error) { return f(path) }
+
+// MapResolver resolves paths from a map keyed by path. It stands in for a real lookup
+// service in tests and examples.
+type MapResolver map[string]interface{}
+
+func (m MapResolver) Resolve(path string) (interface{}, error) {
+	v, ok := m[path]
+	if !ok {
+		return nil, fmt.Errorf("cannot resolve %s", path)
+	}
+	return v, nil
+}
+
+// resolveCache calls the resolver at most once per path for one evaluation (or Session),
+// library sub-traces and Rule(...) references included. Errors are not cached: the next
+// read of the path asks the resolver again, so a transient failure does not stick to the
+// session.
+type resolveCache struct {
+	r       EnvResolver
+	mu      sync.Mutex
+	entries map[string]interface{}
+}
+
+func newResolveCache(r EnvResolver) *resolveCache {
+	return &resolveCache{r: r, entries: map[string]interface{}{}}
+}
+
+// resolver returns the access.Resolver of one trace, recording what it resolved in rp.
+func (c *resolveCache) resolver(rp *resolvedPaths) access.Resolver {
+	return func(path string) (interface{}, error) {
+		c.mu.Lock()
+		v, ok := c.entries[path]
+		c.mu.Unlock()
+		if !ok {
+			var err error
+			if v, err = c.r.Resolve(path); err != nil {
+				rp.failed++
+				return nil, err
+			}
+			c.mu.Lock()
+			c.entries[path] = v
+			c.mu.Unlock()
+		}
+		rp.add(path, v)
+		return v, nil
+	}
+}
+
+// resolvedPaths records the paths one trace resolved; the log lets a Session replay
+// them for an evaluation it serves from its memo.
+type resolvedPaths struct {
+	seen   map[string]interface{}
+	log    []resolvedPath
+	failed int // resolver errors so far
+}
+
+type resolvedPath struct {
+	path string
+	v    interface{}
+}
+
+func newResolvedPaths() *resolvedPaths {
+	return &resolvedPaths{seen: map[string]interface{}{}}
+}
+
+func (r *resolvedPaths) add(path string, v interface{}) {
+	r.seen[path] = v
+	r.log = append(r.log, resolvedPath{path: path, v: v})
+}
+
+// unresolvable returns the names of root that must not go through the resolver: names
+// bound by let and the functions the tracer installs.
+func (t *Tracer) unresolvable(root ast.Node) func(name string) bool {
+	bound := letNames(root)
+	return func(name string) bool {
+		if bound[name] || name == "Cond" || name == "Cond3" || name == "Rule" {
+			return true
+		}
+		if t.library != nil {
+			if _, ok := t.library.Lookup(name); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func letNames(n ast.Node) map[string]bool {
+	v := letCollector{}
+	ast.Walk(&n, v)
+	return v
+}
+
+type letCollector map[string]bool
+
+func (c letCollector) Visit(node *ast.Node) {
+	if d, ok := (*node).(*ast.VariableDeclaratorNode); ok {
+		c[d.Name] = true
+	}
+}
@@ -0,0 +1,72 @@
+package ruletrace
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// flakyResolver answers from values, or fails while down.
+type flakyResolver struct {
+	values map[string]interface{}
+	down   bool
+	calls  map[string]int
+}
+
+func (r *flakyResolver) Resolve(path string) (interface{}, error) {
+	r.calls[path]++
+	if r.down {
+		return nil, errors.New("lookup timed out")
+	}
+	return MapResolver(r.values).Resolve(path)
+}
+
+func TestResolver(t *testing.T) {
+	env := map[string]interface{}{"user": map[string]interface{}{"Id": 7, "Group": "guest"}}
+	rule := `user.Group in ["admin", "moderator"] || user.Id == comment.UserId`
+	tests := []struct {
+		name     string
+		env      map[string]interface{}
+		final    interface{}
+		resolved map[string]interface{}
+	}{
+		{"resolved", env, true, map[string]interface{}{"comment.UserId": 7}},
+		{"short-circuit resolves nothing", map[string]interface{}{"user": map[string]interface{}{"Group": "admin"}}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &flakyResolver{values: map[string]interface{}{"comment.UserId": 7}, calls: map[string]int{}}
+			res := New(tt.env, WithResolver(r)).Trace(rule, nil)
+			if res.Final != tt.final {
+				t.Errorf("Final = %v, want %v", res.Final, tt.final)
+			}
+			if len(res.Resolved) != len(tt.resolved) || (len(tt.resolved) > 0 && !reflect.DeepEqual(res.Resolved, tt.resolved)) {
+				t.Errorf("Resolved = %v, want %v", res.Resolved, tt.resolved)
+			}
+			if r.calls["comment.UserId"] > 1 {
+				t.Errorf("resolver called %d times for comment.UserId, want at most 1", r.calls["comment.UserId"])
+			}
+		})
+	}
+}
+
+func TestResolverErrorsNotCached(t *testing.T) {
+	env := map[string]interface{}{"user": map[string]interface{}{"Id": 7}}
+	r := &flakyResolver{values: map[string]interface{}{"comment.UserId": 7}, down: true, calls: map[string]int{}}
+	s := NewSession(env, WithResolver(r))
+
+	first := s.Trace(`user.Id == comment.UserId`, nil)
+	if first.Final != nil || first.Chunks[0].Error == "" {
+		t.Fatalf("first trace: Final %v, chunk %+v; want the lookup error", first.Final, first.Chunks[0])
+	}
+	r.down = false
+	failed := r.calls["comment.UserId"]
+	second := s.Trace(`user.Id == comment.UserId`, nil)
+	if second.Final != true || second.Chunks[0].Error != "" {
+		t.Errorf("second trace: Final %v, chunk %+v; want a fresh lookup", second.Final, second.Chunks[0])
+	}
+	s.Trace(`comment.UserId > 0`, nil)
+	if n := r.calls["comment.UserId"] - failed; n != 1 {
+		t.Errorf("resolver called %d times after recovering, want 1", n)
+	}
+}
@@ -0,0 +1,163 @@
+package ruletrace
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/patch"
+)
+
+// PrimaryReason returns the reason of the highest-priority decisive chunk, or "" if none.
+//
+// A chunk is decisive when the tracer marked it Decisive, i.e. its operand decided the
+// short-circuit operators above it (in `(a && b) || c` with b false and c true only c
+// is), and it carries a reason; when the rule produced no boolean, it must also be
+// unknown or have errored. Ties on Priority keep evaluation order, so the first decisive
+// chunk wins.
+func (r TraceResult) PrimaryReason() string {
+	best := -1
+	for i, c := range r.Chunks {
+		if c.Skipped || c.Reason == "" || !r.decisive(c) {
+			continue
+		}
+		if best < 0 || c.Priority > r.Chunks[best].Priority {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return r.Chunks[best].Reason
+}
+
+func (r TraceResult) decisive(c EvalResult) bool {
+	if _, ok := r.Final.(bool); ok {
+		return c.Decisive
+	}
+	return c.Decisive && (c.Unknown || c.Error != "")
+}
+
+// Summary renders the outcome as one short sentence built from the chunks, e.g.
+//
+//	Passed because c_group (GROUP_ALLOWED); c_owner was not checked
+//	Failed: NOT_OWNER and NAME_MISMATCH
+//
+// Decisive chunks (see PrimaryReason) explain the outcome; skipped chunks are listed as
+// not checked. Chunks without an ID are named by their expression.
+func (r TraceResult) Summary() string {
+	var (
+		decisive []EvalResult
+		skipped  []string
+	)
+	for _, c := range r.Chunks {
+		if c.Skipped {
+			if c.ID != "" {
+				skipped = append(skipped, c.ID)
+			} else if ids := condIDs(c.Expr); len(ids) > 0 {
+				skipped = append(skipped, ids...)
+			} else {
+				skipped = append(skipped, c.Expr)
+			}
+			continue
+		}
+		if r.decisive(c) {
+			decisive = append(decisive, c)
+		}
+	}
+
+	var sb strings.Builder
+	switch final := r.Final.(type) {
+	case bool:
+		if final {
+			sb.WriteString("Passed")
+			if len(decisive) > 0 {
+				sb.WriteString(" because ")
+				sb.WriteString(joinAnd(mapChunks(decisive, passLabel)))
+			}
+		} else {
+			sb.WriteString("Failed")
+			if len(decisive) > 0 {
+				sb.WriteString(": ")
+				sb.WriteString(joinAnd(mapChunks(decisive, failLabel)))
+			}
+		}
+	case nil:
+		sb.WriteString("No result")
+		if len(decisive) > 0 {
+			sb.WriteString(": ")
+			sb.WriteString(joinAnd(mapChunks(decisive, errorLabel)))
+		}
+	default:
+		fmt.Fprintf(&sb, "Result %v", final)
+	}
+
+	if len(skipped) > 0 {
+		sb.WriteString("; ")
+		sb.WriteString(joinAnd(skipped))
+		if len(skipped) == 1 {
+			sb.WriteString(" was not checked")
+		} else {
+			sb.WriteString(" were not checked")
+		}
+	}
+	return sb.String()
+}
+
+func passLabel(c EvalResult) string {
+	switch {
+	case c.ID != "" && c.Reason != "":
+		return c.ID + " (" + c.Reason + ")"
+	case c.ID != "":
+		return c.ID
+	default:
+		return c.Expr
+	}
+}
+
+func failLabel(c EvalResult) string {
+	switch {
+	case c.Reason != "":
+		return c.Reason
+	case c.ID != "":
+		return c.ID
+	default:
+		return c.Expr
+	}
+}
+
+func errorLabel(c EvalResult) string {
+	if c.Unknown && c.Reason != "" {
+		return c.Reason
+	}
+	if c.Error != "" {
+		return passLabel(c) + " errored"
+	}
+	return passLabel(c)
+}
+
+func mapChunks(cs []EvalResult, label func(EvalResult) string) []string {
+	out := make([]string, len(cs))
+	for i, c := range cs {
+		out[i] = label(c)
+	}
+	return out
+}
+
+// joinAnd joins items as "a", "a and b", "a, b and c".
+func joinAnd(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+// condIDs returns the ids of the Cond calls in a chunk's expression, in source order.
+func condIDs(exprStr string) []string {
+	tree, err := parser.Parse(exprStr)
+	if err != nil {
+		return nil
+	}
+	return patch.CondIDs(tree.Node)
+}
@@ -0,0 +1,284 @@
+package ruletrace
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/access"
+	"github.com/aqilarik/ruletrace/internal/cond"
+	"github.com/aqilarik/ruletrace/internal/format"
+	"github.com/aqilarik/ruletrace/internal/patch"
+)
+
+// Dependent is one atom of a rule and the env paths it reads.
+type Dependent struct {
+	Rule        string
+	Expr        string // canonical atom source
+	Fingerprint string
+	Paths       []string
+}
+
+// DepIndex maps env paths to the atoms and rules that read them, so a caller whose env
+// changed at a few paths knows what to re-evaluate.
+//
+// Paths are found statically (see TraceResult.Inputs for the syntax). A member chain
+// with a dynamic property counts as reading its whole base (`tweets[i].Len` reads
+// tweets), an env function counts as reading its name only, and an atom referencing a
+// library condition or a rule reads whatever that condition or rule reads.
+type DepIndex struct {
+	atoms  []Dependent
+	byPath map[string][]int    // path -> indexes into atoms
+	rules  []string            // rule names, in order
+	paths  map[string][]string // rule -> paths it reads
+}
+
+// NewDepIndex indexes rules, resolving library references with the library installed by
+// opts (WithLibrary) and Rule(...) references among rules.
+func NewDepIndex(rules []Rule, opts ...Option) (*DepIndex, error) {
+	if _, err := NewRegistry(rules...); err != nil {
+		return nil, fmt.Errorf("depindex: %w", err)
+	}
+	d := newDeps(New(nil, opts...).library, rules)
+	ix := &DepIndex{byPath: map[string][]int{}, paths: map[string][]string{}}
+	for _, r := range rules {
+		units, err := d.units(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("depindex: rule %q: %w", r.Name, err)
+		}
+		for _, src := range units {
+			paths := d.atomPaths(src, nil)
+			for _, p := range paths {
+				ix.byPath[p] = append(ix.byPath[p], len(ix.atoms))
+			}
+			ix.atoms = append(ix.atoms, Dependent{Rule: r.Name, Expr: src, Fingerprint: Fingerprint(src), Paths: paths})
+		}
+		ix.rules = append(ix.rules, r.Name)
+		ix.paths[r.Name] = d.rulePaths(r.Name)
+	}
+	return ix, nil
+}
+
+// Paths returns the env paths rule reads, sorted.
+func (ix *DepIndex) Paths(rule string) []string {
+	return append([]string(nil), ix.paths[rule]...)
+}
+
+// Affected returns the atoms reading any of the changed paths, in rule order.
+func (ix *DepIndex) Affected(changed ...string) []Dependent {
+	hit := map[int]bool{}
+	for p, idx := range ix.byPath {
+		if overlapsAny(p, changed) {
+			for _, i := range idx {
+				hit[i] = true
+			}
+		}
+	}
+	out := make([]Dependent, 0, len(hit))
+	for i, a := range ix.atoms {
+		if hit[i] {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Rules returns the names of the rules reading any of the changed paths, in order.
+func (ix *DepIndex) Rules(changed ...string) []string {
+	var out []string
+	for _, name := range ix.rules {
+		for _, p := range ix.paths[name] {
+			if overlapsAny(p, changed) {
+				out = append(out, name)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func overlapsAny(path string, changed []string) bool {
+	for _, c := range changed {
+		if access.Overlaps(path, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// deps resolves the paths read by atoms, library conditions and rules, memoizing the
+// last two.
+type deps struct {
+	conds map[string]NamedCondition
+	rules map[string]Rule
+	memo  map[string][]string // "cond:name" / "rule:name" -> paths
+}
+
+func newDeps(lib *Library, rules []Rule) *deps {
+	d := &deps{conds: map[string]NamedCondition{}, rules: map[string]Rule{}, memo: map[string][]string{}}
+	if lib != nil {
+		d.conds = lib.snapshot()
+	}
+	for _, r := range rules {
+		d.rules[r.Name] = r
+	}
+	return d
+}
+
+// units returns the canonical sources of src's trace units, as evalChunks splits them
+// in atomic mode: short-circuit operands down to atoms, or the operand itself when it
+// holds none.
+func (d *deps) units(src string) ([]string, error) {
+	tree, err := parser.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	fmter := format.New()
+	var out []string
+	var walk func(n ast.Node)
+	walk = func(n ast.Node) {
+		if ch, ok := n.(*ast.ChainNode); ok {
+			walk(ch.Node)
+			return
+		}
+		if bn, ok := n.(*ast.BinaryNode); ok && patch.IsShortCircuitOp(bn.Operator) {
+			walk(bn.Left)
+			walk(bn.Right)
+			return
+		}
+		atoms := patch.CollectAtoms(n)
+		if len(atoms) == 0 {
+			atoms = []ast.Node{n}
+		}
+		for _, a := range atoms {
+			out = append(out, fmter.Format(a))
+		}
+	}
+	walk(tree.Node)
+	return out, nil
+}
+
+// atomPaths returns the paths src reads, params excluded, through library and rule
+// references.
+func (d *deps) atomPaths(src string, params []string) []string {
+	tree, err := parser.Parse(src)
+	if err != nil {
+		return nil
+	}
+	set := map[string]bool{}
+	skip := func(name string) bool {
+		_, isCond := d.conds[name]
+		return isCond || name == "Cond" || name == "Cond3" || name == "Rule" || contains(params, name)
+	}
+	for _, p := range access.Reads(tree.Node, skip) {
+		set[p] = true
+	}
+	for _, name := range referencedNames(src, func(n string) bool { _, ok := d.conds[n]; return ok && !contains(params, n) }) {
+		for _, p := range d.condPaths(name) {
+			set[p] = true
+		}
+	}
+	names, _ := ruleRefs(src)
+	for _, name := range names {
+		for _, p := range d.rulePaths(name) {
+			set[p] = true
+		}
+	}
+	return sortedKeys(set)
+}
+
+func (d *deps) condPaths(name string) []string {
+	key := "cond:" + name
+	if p, ok := d.memo[key]; ok {
+		return p
+	}
+	c := d.conds[name]
+	d.memo[key] = d.atomPaths(c.Expr, c.Params)
+	return d.memo[key]
+}
+
+func (d *deps) rulePaths(name string) []string {
+	key := "rule:" + name
+	if p, ok := d.memo[key]; ok {
+		return p
+	}
+	d.memo[key] = d.atomPaths(d.rules[name].Expr, nil)
+	return d.memo[key]
+}
+
+func sortedKeys(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Retrace updates prev, a trace of the same rule with the same specs and opts, for env
+// in which only the changed paths differ from the env prev was traced against. Atoms of
+// prev that read none of the changed paths keep their outcome; the other atoms, and the
+// operators above them, are evaluated again. The result is the trace Trace would return
+// for env, provided changed lists every path that differs.
+//
+// Atoms referencing a library condition or a rule are always evaluated again, since the
+// sub-traces they carry are not kept in prev, and so are atoms reading through the
+// resolver (WithResolver), to record what they resolve.
+func Retrace(prev TraceResult, specs map[string]ConditionSpec, env map[string]interface{}, changed []string, opts ...Option) TraceResult {
+	s := NewSession(env, opts...)
+	d := newDeps(s.t.library, nil)
+	for _, ch := range prev.Chunks {
+		if ch.Skipped || ch.Sub != nil {
+			continue
+		}
+		tree, err := parser.Parse(ch.Expr)
+		if err != nil {
+			continue
+		}
+		n := tree.Node
+		if !patch.IsAtomNode(n) && !patch.IsCondCall(n) {
+			continue
+		}
+		if refs, _ := ruleRefs(ch.Expr); len(refs) > 0 {
+			continue
+		}
+		if len(referencedNames(ch.Expr, func(n string) bool { _, ok := d.conds[n]; return ok })) > 0 {
+			continue
+		}
+		if paths := d.atomPaths(ch.Expr, nil); affected(paths, changed) || s.t.resolves(paths, env) {
+			continue
+		}
+		var ef evalEffects
+		if patch.IsCondCall(n) && ch.ID != "" {
+			ef.recs = []cond.Recorded{{ID: ch.ID, Value: ch.Value == true, Unknown: ch.Unknown, Reason: ch.Reason}}
+		}
+		s.t.shared.Seed(s.t.evalKey(), ch.Expr, ch.Value, ch.Error, ef)
+	}
+	return s.Trace(prev.Input, specs)
+}
+
+func affected(paths, changed []string) bool {
+	for _, p := range paths {
+		if overlapsAny(p, changed) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolves reports whether reading paths goes through the tracer's resolver.
+func (t *Tracer) resolves(paths []string, env map[string]interface{}) bool {
+	if t.resolver == nil {
+		return false
+	}
+	for _, p := range paths {
+		if name, _, err := access.Split(p); err == nil {
+			if _, ok := env[name]; !ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDepIndex(t *testing.T) {
+	rules := []Rule{
+		{Name: "group", Expr: `user.Group in ["admin", "mod"] || user.Id == comment.UserId`},
+		{Name: "adult", Expr: `user.Profile.Age >= 18`},
+		{Name: "tweets", Expr: `len(tweets) > 1 && tweets[i].Len < 280`},
+		{Name: "both", Expr: `Rule("adult") && !comment.Hidden`},
+	}
+	ix, err := NewDepIndex(rules)
+	if err != nil {
+		t.Fatalf("NewDepIndex: %v", err)
+	}
+	paths := []struct {
+		rule string
+		want []string
+	}{
+		{"group", []string{"comment.UserId", "user.Group", "user.Id"}},
+		{"adult", []string{"user.Profile.Age"}},
+		{"tweets", []string{"i", "tweets"}},
+		{"both", []string{"comment.Hidden", "user.Profile.Age"}},
+	}
+	for _, p := range paths {
+		if got := ix.Paths(p.rule); !reflect.DeepEqual(got, p.want) {
+			t.Errorf("Paths(%s) = %v, want %v", p.rule, got, p.want)
+		}
+	}
+
+	tests := []struct {
+		changed []string
+		rules   []string
+		atoms   []string
+	}{
+		{[]string{"user.Group"}, []string{"group"}, []string{`user.Group in ["admin", "mod"]`}},
+		{[]string{"user"}, []string{"group", "adult", "both"},
+			[]string{`user.Group in ["admin", "mod"]`, `user.Id == comment.UserId`, `user.Profile.Age >= 18`, `Rule("adult")`}},
+		{[]string{"user.Profile"}, []string{"adult", "both"}, []string{`user.Profile.Age >= 18`, `Rule("adult")`}},
+		{[]string{"tweets[3].Len"}, []string{"tweets"}, []string{`len(tweets) > 1`, `tweets[i].Len < 280`}},
+		{[]string{"user.Name"}, nil, nil},
+	}
+	for _, tt := range tests {
+		if got := ix.Rules(tt.changed...); !reflect.DeepEqual(got, tt.rules) {
+			t.Errorf("Rules(%v) = %v, want %v", tt.changed, got, tt.rules)
+		}
+		var atoms []string
+		for _, a := range ix.Affected(tt.changed...) {
+			atoms = append(atoms, a.Expr)
+		}
+		if !reflect.DeepEqual(atoms, tt.atoms) {
+			t.Errorf("Affected(%v) = %v, want %v", tt.changed, atoms, tt.atoms)
+		}
+	}
+
+	if _, err := NewDepIndex([]Rule{{Name: "a", Expr: `Rule("missing")`}}); err == nil {
+		t.Error("NewDepIndex with an unknown reference: want an error")
+	}
+}
+
+func TestRetrace(t *testing.T) {
+	const rule = `score(user.Age) > 10 && user.Group == "admin" || user.Id == comment.UserId`
+	specs := map[string]ConditionSpec{
+		Fingerprint(`user.Group == "admin"`): {ID: "c_group", ReasonTrue: "ADMIN", ReasonFalse: "NOT_ADMIN"},
+	}
+	calls := 0
+	score := func(age int) int {
+		calls++
+		return age
+	}
+	env := func(group string, age, owner int) map[string]interface{} {
+		return map[string]interface{}{
+			"user":    map[string]interface{}{"Group": group, "Age": age, "Id": 1},
+			"comment": map[string]interface{}{"UserId": owner},
+			"score":   score,
+		}
+	}
+	tests := []struct {
+		name     string
+		before   map[string]interface{}
+		after    map[string]interface{}
+		changed  []string
+		rescored bool
+	}{
+		{"unrelated atom changed", env("admin", 20, 1), env("guest", 20, 1), []string{"user.Group"}, false},
+		{"short-circuited atom now evaluated", env("admin", 20, 2), env("guest", 20, 1), []string{"user.Group", "comment.UserId"}, false},
+		{"cached atom changed", env("admin", 20, 1), env("admin", 5, 1), []string{"user.Age"}, true},
+		{"parent path changed", env("admin", 20, 1), env("admin", 5, 1), []string{"user"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := New(tt.before, WithMode(TraceAtomic)).Trace(rule, specs)
+			calls = 0
+			got := Retrace(prev, specs, tt.after, tt.changed, WithMode(TraceAtomic))
+			if (calls > 0) != tt.rescored {
+				t.Errorf("score called %d times, want rescored %v", calls, tt.rescored)
+			}
+			want := New(tt.after, WithMode(TraceAtomic)).Trace(rule, specs)
+			if got.Final != want.Final || !reflect.DeepEqual(got.Chunks, want.Chunks) {
+				t.Fatalf("Retrace = %v %+v\nTrace = %v %+v", got.Final, got.Chunks, want.Final, want.Chunks)
+			}
+		})
+	}
+}
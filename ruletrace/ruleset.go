@@ -0,0 +1,238 @@
+package ruletrace
+
+import (
+	"fmt"
+
+	"github.com/expr-lang/expr/parser"
+)
+
+// Effect is what a rule yields when its expression evaluates to true.
+type Effect uint8
+
+const (
+	EffectPermit Effect = iota
+	EffectDeny
+)
+
+func (e Effect) String() string {
+	return [...]string{"permit", "deny"}[e]
+}
+
+// Decision is the outcome of a rule or a rule set.
+type Decision uint8
+
+const (
+	DecisionNotApplicable Decision = iota // no rule matched
+	DecisionPermit
+	DecisionDeny
+	DecisionIndeterminate // a rule that could have decided errored or was not bool
+)
+
+func (d Decision) String() string {
+	return [...]string{"not-applicable", "permit", "deny", "indeterminate"}[d]
+}
+
+// Combining selects how a RuleSet combines its rules' outcomes. Rules run in order and
+// stop as soon as the outcome is settled; the remaining rules are reported as skipped.
+//
+//   - CombineFirstApplicable: the Effect of the first rule that evaluates to true.
+//   - CombineAllMustPass: permit if every rule is true, deny at the first false one.
+//   - CombineAnyPasses: permit at the first true rule, deny if none is.
+//   - CombineDenyOverrides: deny if any deny rule is true, else permit if a permit rule is.
+//   - CombinePermitOverrides: permit if any permit rule is true, else deny if a deny rule is.
+//
+// AllMustPass and AnyPasses ignore Effect. A rule that errors or does not produce a bool
+// makes the set indeterminate where its outcome could have changed the decision.
+type Combining uint8
+
+const (
+	CombineFirstApplicable Combining = iota
+	CombineAllMustPass
+	CombineAnyPasses
+	CombineDenyOverrides
+	CombinePermitOverrides
+)
+
+func (c Combining) String() string {
+	return [...]string{"first-applicable", "all-must-pass", "any-passes", "deny-overrides", "permit-overrides"}[c]
+}
+
+// Rule is a named expression with its specs, as registered in a RuleSet.
+type Rule struct {
+	Name   string
+	Expr   string
+	Specs  map[string]ConditionSpec
+	Effect Effect
+}
+
+// RuleOutcome is one rule's part in a RuleSet evaluation.
+type RuleOutcome struct {
+	Name     string
+	Effect   Effect
+	Trace    TraceResult // zero when Skipped
+	Matched  bool        // Final was true
+	Errored  bool        // Final was not a bool
+	Skipped  bool        // not evaluated, the decision was already settled
+	Decisive bool        // this rule settled the decision
+}
+
+// SetResult is the aggregate decision of a RuleSet and every rule's outcome, in order.
+type SetResult struct {
+	Decision  Decision
+	Combining Combining
+	DecidedBy string // name of the decisive rule, "" if no single rule decided
+	Rules     []RuleOutcome
+	Stats     SessionStats // evaluations shared between the rules (see Session)
+}
+
+// RuleSet evaluates named rules against one env and combines their outcomes.
+type RuleSet struct {
+	combining Combining
+	rules     []Rule
+	registry  *Registry
+}
+
+// NewRuleSet validates the rules (unique non-empty names, parseable expressions, valid
+// specs, no Rule(...) reference cycles) and keeps them in order.
+func NewRuleSet(c Combining, rules ...Rule) (*RuleSet, error) {
+	seen := map[string]bool{}
+	for _, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("ruleset: rule with empty name")
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("ruleset: duplicate rule %q", r.Name)
+		}
+		seen[r.Name] = true
+		if _, err := parser.Parse(r.Expr); err != nil {
+			return nil, fmt.Errorf("ruleset: rule %q: %w", r.Name, err)
+		}
+		if err := ValidateSpecs(r.Specs); err != nil {
+			return nil, fmt.Errorf("ruleset: rule %q: %w", r.Name, err)
+		}
+	}
+	reg, err := newRegistry(rules, false)
+	if err != nil {
+		return nil, fmt.Errorf("ruleset: %w", err)
+	}
+	return &RuleSet{combining: c, rules: append([]Rule(nil), rules...), registry: reg}, nil
+}
+
+// Rules returns the rules in evaluation order.
+func (rs *RuleSet) Rules() []Rule { return append([]Rule(nil), rs.rules...) }
+
+// Combining returns the set's combining algorithm.
+func (rs *RuleSet) Combining() Combining { return rs.combining }
+
+// Evaluate traces the rules against env with opts, in order, until the combining
+// algorithm settles the decision. Rules can reference each other with Rule("name")
+// (unless opts install another registry with WithRules); a rule already traced through
+// a reference is not traced again, and rules share atom evaluations as in a Session.
+func (rs *RuleSet) Evaluate(env map[string]interface{}, opts ...Option) SetResult {
+	s := NewSession(env, append([]Option{WithRules(rs.registry)}, opts...)...)
+	t := s.t
+	res := SetResult{Combining: rs.combining, Rules: make([]RuleOutcome, len(rs.rules))}
+	for i, r := range rs.rules {
+		res.Rules[i] = RuleOutcome{Name: r.Name, Effect: r.Effect, Skipped: true}
+	}
+
+	var (
+		permit, deny             = -1, -1 // first matching rule per effect
+		errPermit, errDeny, errs bool
+	)
+	decide := func(d Decision, i int) SetResult {
+		res.Decision = d
+		res.Stats = s.Stats()
+		if i >= 0 {
+			res.Rules[i].Decisive = true
+			res.DecidedBy = res.Rules[i].Name
+		}
+		return res
+	}
+
+	for i, r := range rs.rules {
+		out := &res.Rules[i]
+		out.Skipped = false
+		out.Trace = *t.traceRule(r)
+		b, ok := out.Trace.Final.(bool)
+		out.Matched, out.Errored = ok && b, !ok
+
+		switch rs.combining {
+		case CombineFirstApplicable:
+			if out.Errored {
+				return decide(DecisionIndeterminate, i)
+			}
+			if out.Matched {
+				return decide(effectDecision(r.Effect), i)
+			}
+		case CombineAllMustPass:
+			if out.Errored {
+				return decide(DecisionIndeterminate, i)
+			}
+			if !out.Matched {
+				return decide(DecisionDeny, i)
+			}
+		case CombineAnyPasses:
+			if out.Matched {
+				return decide(DecisionPermit, i)
+			}
+			errs = errs || out.Errored
+		case CombineDenyOverrides, CombinePermitOverrides:
+			overriding := EffectDeny
+			if rs.combining == CombinePermitOverrides {
+				overriding = EffectPermit
+			}
+			if out.Matched && r.Effect == overriding {
+				return decide(effectDecision(r.Effect), i)
+			}
+			switch {
+			case out.Matched && r.Effect == EffectPermit && permit < 0:
+				permit = i
+			case out.Matched && r.Effect == EffectDeny && deny < 0:
+				deny = i
+			case out.Errored && r.Effect == EffectPermit:
+				errPermit = true
+			case out.Errored && r.Effect == EffectDeny:
+				errDeny = true
+			}
+		}
+	}
+
+	switch rs.combining {
+	case CombineFirstApplicable:
+		return decide(DecisionNotApplicable, -1)
+	case CombineAllMustPass:
+		return decide(DecisionPermit, -1)
+	case CombineAnyPasses:
+		if errs {
+			return decide(DecisionIndeterminate, -1)
+		}
+		return decide(DecisionDeny, -1)
+	case CombineDenyOverrides:
+		return overridden(decide, errDeny, permit, DecisionPermit, errPermit)
+	default:
+		return overridden(decide, errPermit, deny, DecisionDeny, errDeny)
+	}
+}
+
+// overridden settles an overrides algorithm once no overriding rule matched: an errored
+// overriding rule could have won, so it makes the set indeterminate; otherwise the first
+// matching rule of the other effect decides.
+func overridden(decide func(Decision, int) SetResult, errOverriding bool, other int, otherDecision Decision, errOther bool) SetResult {
+	switch {
+	case errOverriding:
+		return decide(DecisionIndeterminate, -1)
+	case other >= 0:
+		return decide(otherDecision, other)
+	case errOther:
+		return decide(DecisionIndeterminate, -1)
+	}
+	return decide(DecisionNotApplicable, -1)
+}
+
+func effectDecision(e Effect) Decision {
+	if e == EffectDeny {
+		return DecisionDeny
+	}
+	return DecisionPermit
+}
@@ -0,0 +1,111 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestRuleSetCombining runs the rules p1 (permit), d (deny) and p2 (permit), each just
+// reading its own env flag; a flag of "x" makes the rule non-boolean.
+func TestRuleSetCombining(t *testing.T) {
+	rules := []Rule{
+		{Name: "p1", Expr: `p1`, Effect: EffectPermit},
+		{Name: "d", Expr: `d`, Effect: EffectDeny},
+		{Name: "p2", Expr: `p2`, Effect: EffectPermit},
+	}
+	tests := []struct {
+		name      string
+		combining Combining
+		p1, d, p2 interface{}
+		decision  Decision
+		decidedBy string
+		skipped   []string
+	}{
+		{"first applicable, first match", CombineFirstApplicable, false, true, true, DecisionDeny, "d", []string{"p2"}},
+		{"first applicable, none", CombineFirstApplicable, false, false, false, DecisionNotApplicable, "", nil},
+		{"first applicable, error first", CombineFirstApplicable, "x", true, true, DecisionIndeterminate, "p1", []string{"d", "p2"}},
+
+		{"all must pass", CombineAllMustPass, true, true, true, DecisionPermit, "", nil},
+		{"all must pass, one fails", CombineAllMustPass, true, false, true, DecisionDeny, "d", []string{"p2"}},
+		{"all must pass, error", CombineAllMustPass, "x", false, true, DecisionIndeterminate, "p1", []string{"d", "p2"}},
+
+		{"any passes", CombineAnyPasses, false, true, true, DecisionPermit, "d", []string{"p2"}},
+		{"any passes, none", CombineAnyPasses, false, false, false, DecisionDeny, "", nil},
+		{"any passes, error and none", CombineAnyPasses, "x", false, false, DecisionIndeterminate, "", nil},
+		{"any passes, error then match", CombineAnyPasses, "x", true, false, DecisionPermit, "d", []string{"p2"}},
+
+		{"deny overrides", CombineDenyOverrides, true, true, true, DecisionDeny, "d", []string{"p2"}},
+		{"deny overrides, permit", CombineDenyOverrides, false, false, true, DecisionPermit, "p2", nil},
+		{"deny overrides, first permit decides", CombineDenyOverrides, true, false, true, DecisionPermit, "p1", nil},
+		{"deny overrides, deny errored", CombineDenyOverrides, true, "x", true, DecisionIndeterminate, "", nil},
+		{"deny overrides, permit errored", CombineDenyOverrides, "x", false, false, DecisionIndeterminate, "", nil},
+		{"deny overrides, permit errored, other permit", CombineDenyOverrides, "x", false, true, DecisionPermit, "p2", nil},
+		{"deny overrides, nothing", CombineDenyOverrides, false, false, false, DecisionNotApplicable, "", nil},
+
+		{"permit overrides", CombinePermitOverrides, false, true, true, DecisionPermit, "p2", nil},
+		{"permit overrides, first permit", CombinePermitOverrides, true, true, true, DecisionPermit, "p1", []string{"d", "p2"}},
+		{"permit overrides, deny", CombinePermitOverrides, false, true, false, DecisionDeny, "d", nil},
+		{"permit overrides, permit errored", CombinePermitOverrides, "x", true, false, DecisionIndeterminate, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := NewRuleSet(tt.combining, rules...)
+			if err != nil {
+				t.Fatalf("NewRuleSet: %v", err)
+			}
+			res := rs.Evaluate(map[string]interface{}{"p1": tt.p1, "d": tt.d, "p2": tt.p2})
+			if res.Decision != tt.decision || res.DecidedBy != tt.decidedBy {
+				t.Fatalf("Decision %v by %q, want %v by %q", res.Decision, res.DecidedBy, tt.decision, tt.decidedBy)
+			}
+			var skipped []string
+			for _, r := range res.Rules {
+				if r.Skipped {
+					skipped = append(skipped, r.Name)
+				}
+				if r.Decisive != (r.Name == tt.decidedBy) {
+					t.Errorf("rule %s Decisive = %v", r.Name, r.Decisive)
+				}
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("skipped = %v, want %v", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestNewRuleSetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+	}{
+		{"empty name", []Rule{{Expr: `a`}}},
+		{"duplicate name", []Rule{{Name: "a", Expr: `x`}, {Name: "a", Expr: `y`}}},
+		{"parse error", []Rule{{Name: "a", Expr: `x >`}}},
+		{"spec without ID", []Rule{{Name: "a", Expr: `x > 1`, Specs: map[string]ConditionSpec{Fingerprint(`x > 1`): {}}}}},
+		{"reference cycle", []Rule{{Name: "a", Expr: `Rule("b")`}, {Name: "b", Expr: `Rule("a")`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRuleSet(CombineFirstApplicable, tt.rules...); err == nil {
+				t.Fatal("NewRuleSet: want an error")
+			}
+		})
+	}
+}
+
+func TestRuleSetSharesEvaluations(t *testing.T) {
+	rs, err := NewRuleSet(CombineAllMustPass,
+		Rule{Name: "adult", Expr: `user.Age >= 18 && user.Active`},
+		Rule{Name: "adult again", Expr: `user.Active && user.Age >= 18`},
+	)
+	if err != nil {
+		t.Fatalf("NewRuleSet: %v", err)
+	}
+	res := rs.Evaluate(map[string]interface{}{"user": map[string]interface{}{"Age": 20, "Active": true}})
+	if res.Decision != DecisionPermit {
+		t.Fatalf("Decision = %v, want permit", res.Decision)
+	}
+	if res.Stats.Computed >= res.Stats.Requested {
+		t.Errorf("Stats = %+v, want shared evaluations", res.Stats)
+	}
+}
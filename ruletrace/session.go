@@ -0,0 +1,213 @@
+package ruletrace
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/cond"
+	"github.com/aqilarik/ruletrace/internal/eval"
+	"github.com/aqilarik/ruletrace/internal/format"
+	"github.com/aqilarik/ruletrace/internal/patch"
+)
+
+// Session traces many rules against one env and evaluates each distinct expression
+// (atom, sub-expression or whole rule, keyed by its canonical source) at most once,
+// sharing the result across the rules' traces. Rule(...) references are memoized for
+// the whole session too.
+//
+// The env must not change during the session. A Session is not safe for concurrent use.
+type Session struct {
+	t *Tracer
+}
+
+// SessionStats reports how much evaluation a Session shared.
+type SessionStats struct {
+	Requested int // evaluations the traces asked for
+	Computed  int // evaluations that actually ran
+}
+
+// DedupRatio is the share of requested evaluations served from the session memo.
+func (s SessionStats) DedupRatio() float64 {
+	if s.Requested == 0 {
+		return 0
+	}
+	return float64(s.Requested-s.Computed) / float64(s.Requested)
+}
+
+// NewSession creates a session over env with tracer options.
+func NewSession(env map[string]interface{}, opts ...Option) *Session {
+	t := New(env, opts...)
+	t.shared = eval.NewMemo()
+	t.memo = newRuleMemo()
+	if t.resolver != nil {
+		t.resolved = newResolveCache(t.resolver)
+	}
+	return &Session{t: t}
+}
+
+func (s *Session) Trace(input string, specs map[string]ConditionSpec) TraceResult {
+	return s.t.Trace(input, specs)
+}
+
+func (s *Session) TraceStrict(input string, specs map[string]ConditionSpec) (TraceResult, error) {
+	return s.t.TraceStrict(input, specs)
+}
+
+// Stats returns the evaluations shared so far.
+func (s *Session) Stats() SessionStats {
+	req, comp := s.t.shared.Stats()
+	return SessionStats{Requested: req, Computed: comp}
+}
+
+// evalKey identifies the options that change what a source evaluates to, keying the
+// tracer's entries in a session memo: the functions it installs (Cond, Cond3 and the
+// unknown policy, library conditions, Rule), undefined names and the resolver.
+func (t *Tracer) evalKey() string {
+	return fmt.Sprintf("cond=%t unknown=%d undefined=%t library=%p rules=%p resolver=%t",
+		t.enableCond, t.unknown, t.undefined, t.library, t.rules, t.resolver != nil)
+}
+
+// traceEffects replays, on a session memo hit, what evaluating a source did to the
+// trace: the Cond outcomes it recorded and the paths it resolved. Evaluations that called
+// a library condition are not memoized, since their sub-traces depend on the calling trace,
+// and neither are those the resolver failed for, so a later evaluation asks again.
+type traceEffects struct {
+	rec *cond.Recorder
+	lc  *libraryCalls
+	rp  *resolvedPaths // nil without a resolver
+}
+
+type evalEffects struct {
+	recs     []cond.Recorded
+	resolved []resolvedPath
+}
+
+func (e traceEffects) Begin() func() (interface{}, bool) {
+	mark, calls, resolved, failed := e.rec.Mark(), e.lc.calls, 0, 0
+	if e.rp != nil {
+		resolved, failed = len(e.rp.log), e.rp.failed
+	}
+	return func() (interface{}, bool) {
+		out := evalEffects{recs: e.rec.Since(mark)}
+		ok := e.lc.calls == calls
+		if e.rp != nil {
+			out.resolved = append([]resolvedPath(nil), e.rp.log[resolved:]...)
+			ok = ok && e.rp.failed == failed
+		}
+		return out, ok
+	}
+}
+
+func (e traceEffects) Replay(recorded interface{}) {
+	ef := recorded.(evalEffects)
+	e.rec.Replay(ef.recs)
+	if e.rp != nil {
+		for _, r := range ef.resolved {
+			e.rp.add(r.path, r.v)
+		}
+	}
+}
+
+// atomFunc is the function atomMemoizer calls in place of an atom.
+const atomFunc = "$atom"
+
+// atomFuncOption implements atomFunc: it evaluates the atom source through the session
+// cache ec, so the atom runs at most once per session however many programs contain it.
+func (t *Tracer) atomFuncOption(ec *eval.Cache, opts *[]expr.Option) expr.Option {
+	return expr.Function(atomFunc, func(params ...any) (any, error) {
+		v, errStr := eval.EvalString(params[0].(string), t.env, ec, *opts...)
+		if errStr != "" {
+			return nil, errors.New(errStr)
+		}
+		return v, nil
+	})
+}
+
+// atomMemoizer rewrites, in a program compiled for a session trace, every atom other than
+// the program itself into atomFunc("<atom source>"). Evaluating a rule or an operator
+// chunk then reuses the atoms its chunks already evaluated, and short-circuiting still
+// decides which atoms run. Atoms reading closure or let variables are left in place.
+//
+// ast.Walk visits children first, so an atom's inner atoms are restored to their
+// original nodes before the atom is formatted and rewritten as a whole.
+type atomMemoizer struct {
+	root       string
+	env        map[string]interface{}
+	resolvable func(name string) bool // names read through the resolver
+	orig       map[*ast.CallNode]ast.Node
+}
+
+func (t *Tracer) atomPatch(src string) ast.Visitor {
+	m := &atomMemoizer{root: src, env: t.env, orig: map[*ast.CallNode]ast.Node{}}
+	m.resolvable = func(string) bool { return false }
+	if t.resolver != nil {
+		if tree, err := parser.Parse(src); err == nil {
+			skip := t.unresolvable(tree.Node)
+			m.resolvable = func(name string) bool { return !skip(name) }
+		}
+	}
+	return m
+}
+
+func (m *atomMemoizer) Visit(node *ast.Node) {
+	n := *node
+	if _, ok := patch.RuleCallName(n); !ok && !patch.IsAtomNode(n) && !patch.IsCondCall(n) {
+		return
+	}
+	ast.Walk(node, atomRestorer(m.orig))
+	if !m.standalone(*node) {
+		return
+	}
+	src := format.New().Format(*node)
+	if src == m.root {
+		return
+	}
+	call := &ast.CallNode{
+		Callee:    &ast.IdentifierNode{Value: atomFunc},
+		Arguments: []ast.Node{&ast.StringNode{Value: src}},
+	}
+	m.orig[call] = *node
+	ast.Patch(node, call)
+}
+
+// standalone reports whether n can be evaluated on its own against the env: it reads no
+// closure pointer and no identifier other than env names, resolvable names and callees.
+func (m *atomMemoizer) standalone(n ast.Node) bool {
+	callees := map[ast.Node]bool{}
+	bound := ast.Find(n, func(x ast.Node) bool {
+		switch x := x.(type) {
+		case *ast.CallNode:
+			callees[x.Callee] = true
+		case *ast.PointerNode, *ast.VariableDeclaratorNode, *ast.PredicateNode:
+			return true
+		}
+		return false
+	})
+	if bound != nil {
+		return false
+	}
+	free := ast.Find(n, func(x ast.Node) bool {
+		id, ok := x.(*ast.IdentifierNode)
+		if !ok || callees[x] {
+			return false
+		}
+		_, inEnv := m.env[id.Value]
+		return !inEnv && !m.resolvable(id.Value)
+	})
+	return free == nil
+}
+
+// atomRestorer undoes atomMemoizer rewrites below a node.
+type atomRestorer map[*ast.CallNode]ast.Node
+
+func (r atomRestorer) Visit(node *ast.Node) {
+	if call, ok := (*node).(*ast.CallNode); ok {
+		if orig, ok := r[call]; ok {
+			*node = orig
+		}
+	}
+}
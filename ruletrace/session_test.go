@@ -0,0 +1,47 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	env := map[string]interface{}{"user": map[string]interface{}{"Age": 20, "Group": "admin", "Banned": false}}
+	specs := map[string]ConditionSpec{
+		Fingerprint(`user.Age >= 18`): {ID: "c_age", ReasonTrue: "ADULT", ReasonFalse: "MINOR"},
+	}
+	rules := []string{
+		`user.Age >= 18 && user.Group == "admin"`,
+		`user.Age >= 18 && !user.Banned`,
+		`user.Group == "admin" || user.Age >= 18`,
+	}
+	s := NewSession(env)
+	for _, r := range rules {
+		got := s.Trace(r, specs)
+		want := New(env).Trace(r, specs)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: session trace differs\n got %+v\nwant %+v", r, got, want)
+		}
+	}
+	st := s.Stats()
+	if st.Computed >= st.Requested || st.DedupRatio() <= 0 {
+		t.Errorf("Stats = %+v, want shared evaluations", st)
+	}
+}
+
+func TestSessionLibraryCalls(t *testing.T) {
+	env := map[string]interface{}{
+		"author": map[string]interface{}{"Id": 1},
+		"editor": map[string]interface{}{"Id": 2},
+		"post":   map[string]interface{}{"OwnerId": 2, "Published": true},
+	}
+	s := NewSession(env, WithLibrary(ownerLibrary(t)))
+	for _, r := range []string{`is_owner(author) || is_owner(editor)`, `is_owner(editor) && published`} {
+		res := s.Trace(r, nil)
+		for _, c := range res.Chunks {
+			if c.Sub == nil || c.Sub.Final != c.Value {
+				t.Errorf("%s: chunk %s has Sub %+v, value %v", r, c.Expr, c.Sub, c.Value)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func skippedExprs(res TraceResult) []string {
+	var out []string
+	for _, c := range res.Chunks {
+		if c.Skipped {
+			out = append(out, c.Expr)
+		}
+	}
+	return out
+}
+
+func TestShortCircuit(t *testing.T) {
+	env := map[string]interface{}{"a": false, "b": true, "c": true, "t": true, "n": nil, "s": "x"}
+	tests := []struct {
+		name    string
+		rule    string
+		final   interface{}
+		skipped []string
+	}{
+		{"negated left decides", `not a || c`, true, []string{"c"}},
+		{"nested or decides", `(t || b) || c`, true, []string{"b", "c"}},
+		{"nested and decides", `(a && b) && c`, false, []string{"b", "c"}},
+		{"negated atom", `!(s == "x") && c`, false, []string{"c"}},
+		{"right decides", `a || (b && c)`, true, nil},
+		{"coalesce", `(s ?? n) == "x" && (n ?? s) == "x"`, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := New(env).Trace(tt.rule, nil)
+			if res.Final != tt.final {
+				t.Errorf("Final = %v, want %v", res.Final, tt.final)
+			}
+			if got := skippedExprs(res); !reflect.DeepEqual(got, tt.skipped) {
+				t.Errorf("skipped = %q, want %q", got, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestShortCircuitEvaluatesOnce(t *testing.T) {
+	calls := 0
+	env := map[string]interface{}{
+		"f": func() bool { calls++; return true },
+		"b": true,
+		"c": true,
+	}
+	res := New(env).Trace(`(f() || b) || c`, nil)
+	if res.Final != true {
+		t.Fatalf("Final = %v, want true", res.Final)
+	}
+	// once for its chunk, once for the final run
+	if calls != 2 {
+		t.Errorf("f called %d times, want 2", calls)
+	}
+}
+
+func TestSkippedCondIDs(t *testing.T) {
+	specs := map[string]ConditionSpec{
+		Fingerprint(`a == true`): {ID: "c_a", ReasonTrue: "A", ReasonFalse: "NOT_A"},
+		Fingerprint(`b == "x"`):  {ID: "c_b", ReasonTrue: "B", ReasonFalse: "NOT_B"},
+		Fingerprint(`c > 1`):     {ID: "c_c", ReasonTrue: "C", ReasonFalse: "NOT_C"},
+	}
+	env := map[string]interface{}{"a": false, "b": "y", "c": 2}
+	res := New(env, WithMode(TraceCoarse)).Trace(`a == true && (b == "x" || c > 1)`, specs)
+	want := `Failed: NOT_A; c_b and c_c were not checked`
+	if got := res.Summary(); got != want {
+		t.Errorf("Summary = %q, want %q", got, want)
+	}
+}
+
+func TestSummaryDecisive(t *testing.T) {
+	specs := map[string]ConditionSpec{
+		Fingerprint(`a == true`): {ID: "c_a", ReasonTrue: "A", ReasonFalse: "NOT_A"},
+		Fingerprint(`b == "x"`):  {ID: "c_b", ReasonTrue: "B", ReasonFalse: "NOT_B"},
+		Fingerprint(`c > 1`):     {ID: "c_c", ReasonTrue: "C", ReasonFalse: "NOT_C"},
+	}
+	tests := []struct {
+		rule string
+		env  map[string]interface{}
+		want string
+	}{
+		{`(a == true && b == "x") || c > 1`, map[string]interface{}{"a": true, "b": "y", "c": 2}, `Passed because c_c (C)`},
+		{`(a == true && b == "x") || c > 1`, map[string]interface{}{"a": true, "b": "y", "c": 0}, `Failed: NOT_B and NOT_C`},
+		{`(a == true && b == "x") || c > 1`, map[string]interface{}{"a": true, "b": "x", "c": 0}, `Passed because c_a (A) and c_b (B); c_c was not checked`},
+		{`!(a == true) && c > 1`, map[string]interface{}{"a": false, "c": 2}, `Passed because c_a (NOT_A) and c_c (C)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			res := New(tt.env, WithMode(TraceAtomic)).Trace(tt.rule, specs)
+			if got := res.Summary(); got != tt.want {
+				t.Errorf("Summary = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package ruletrace
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/expr-lang/expr"
+
+	"github.com/aqilarik/ruletrace/internal/access"
+)
+
+// Override sets an env path (same syntax as TraceResult.Inputs keys, e.g. "user.Group"
+// or `tweets[0].Len`) to a value for a what-if run.
+type Override struct {
+	Path  string
+	Value interface{}
+}
+
+// ParseOverride parses `path = value`, e.g. `user.Group = "guest"`. The value is an expr
+// literal: a string, number, bool, nil, or a list or map of those.
+func ParseOverride(s string) (Override, error) {
+	i := assignIndex(s)
+	if i < 0 {
+		return Override{}, fmt.Errorf("override %q: expected path = value", s)
+	}
+	path, src := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if _, _, err := access.Split(path); err != nil || path == "" {
+		return Override{}, fmt.Errorf("override %q: invalid path", s)
+	}
+	v, err := expr.Eval(src, nil)
+	if err != nil {
+		return Override{}, fmt.Errorf("override %q: %w", s, err)
+	}
+	return Override{Path: path, Value: v}, nil
+}
+
+// assignIndex returns the index of the first `=` outside a quoted key, or -1.
+func assignIndex(s string) int {
+	inStr := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case inStr && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			inStr = !inStr
+		case !inStr && s[i] == '=':
+			return i
+		}
+	}
+	return -1
+}
+
+// Simulation is the outcome of a what-if run.
+type Simulation struct {
+	Before TraceResult // rule traced against the original env
+	After  TraceResult // rule traced against the env with overrides applied
+	Diff   TraceDiff
+}
+
+// Simulate traces rule against env and against a copy of env with the overrides
+// applied, and diffs the two. env is never modified: only the maps, slices and structs
+// along each overridden path are copied. Overrides apply in order, so a later one can
+// refine an earlier one (`user = {}` then `user.Group = "guest"`).
+func Simulate(rule string, specs map[string]ConditionSpec, env map[string]interface{}, overrides []Override, opts ...Option) (Simulation, error) {
+	patched := env
+	for _, o := range overrides {
+		var err error
+		if patched, err = access.With(patched, o.Path, o.Value); err != nil {
+			return Simulation{}, fmt.Errorf("simulate: %w", err)
+		}
+	}
+
+	before, err := New(env, opts...).TraceStrict(rule, specs)
+	if err != nil {
+		return Simulation{}, fmt.Errorf("simulate: %w", err)
+	}
+	after, err := New(patched, opts...).TraceStrict(rule, specs)
+	if err != nil {
+		return Simulation{}, fmt.Errorf("simulate: %w", err)
+	}
+	return Simulation{Before: before, After: after, Diff: Diff(before, after)}, nil
+}
@@ -0,0 +1,95 @@
+package ruletrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOverride(t *testing.T) {
+	tests := []struct {
+		src     string
+		want    Override
+		wantErr bool
+	}{
+		{`user.Group = "guest"`, Override{"user.Group", "guest"}, false},
+		{`user.Age=17`, Override{"user.Age", 17}, false},
+		{`tweets[0].Len = 1.5`, Override{"tweets[0].Len", 1.5}, false},
+		{`comment["a = b"] = true`, Override{`comment["a = b"]`, true}, false},
+		{`user = {"Group": "x"}`, Override{"user", map[string]interface{}{"Group": "x"}}, false},
+		{`user.Tags = ["a", nil]`, Override{"user.Tags", []interface{}{"a", nil}}, false},
+		{`user.Deleted = nil`, Override{"user.Deleted", nil}, false},
+		{`user.Group == "x"`, Override{}, true},
+		{`user.Group`, Override{}, true},
+		{` = 1`, Override{}, true},
+		{`user..Group = 1`, Override{}, true},
+		{`user.Group = guest`, Override{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := ParseOverride(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOverride error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseOverride = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	const rule = `user.Group in ["admin", "moderator"] || user.Id == comment.UserId`
+	tests := []struct {
+		name      string
+		overrides []string
+		final     interface{}
+		changes   []change
+	}{
+		{"no overrides", nil, true, nil},
+		{"demote", []string{`user.Group = "guest"`}, false,
+			[]change{{"c_group", ChangeValue}, {"c_owner", ChangeEvaluated}}},
+		{"demote, then own the comment", []string{`user.Group = "guest"`, `comment.UserId = 2`}, true,
+			[]change{{"c_group", ChangeValue}, {"c_owner", ChangeEvaluated}}},
+		{"replace, then refine", []string{`user = {"Id": 2}`, `user.Group = "moderator"`}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := ownerEnv("admin", 2)
+			var overrides []Override
+			for _, s := range tt.overrides {
+				o, err := ParseOverride(s)
+				if err != nil {
+					t.Fatalf("ParseOverride: %v", err)
+				}
+				overrides = append(overrides, o)
+			}
+			sim, err := Simulate(rule, ownerSpecs(), env, overrides, WithMode(TraceAtomic))
+			if err != nil {
+				t.Fatalf("Simulate: %v", err)
+			}
+			if sim.Before.Final != true || sim.After.Final != tt.final {
+				t.Errorf("Final %v -> %v, want true -> %v", sim.Before.Final, sim.After.Final, tt.final)
+			}
+			var got []change
+			for _, c := range sim.Diff.Changes {
+				got = append(got, change{c.Key, c.Kind})
+			}
+			if !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("changes = %v, want %v", got, tt.changes)
+			}
+			if !reflect.DeepEqual(env, ownerEnv("admin", 2)) {
+				t.Errorf("env was modified: %v", env)
+			}
+		})
+	}
+}
+
+func TestSimulateErrors(t *testing.T) {
+	env := map[string]interface{}{"user": "bob"}
+	if _, err := Simulate(`user == "bob"`, nil, env, []Override{{Path: "user.Name", Value: 1}}); err == nil {
+		t.Error("override through a string: want an error")
+	}
+	if _, err := Simulate(`user ==`, nil, env, nil); err == nil {
+		t.Error("invalid rule: want an error")
+	}
+}
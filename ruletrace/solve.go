@@ -0,0 +1,495 @@
+package ruletrace
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/access"
+	"github.com/aqilarik/ruletrace/internal/patch"
+)
+
+// solveBudget bounds the partial evaluations one search may run.
+const solveBudget = 20000
+
+// SatResult tells whether a rule can pass and whether it can fail, with a witness env
+// for each outcome that can happen.
+type SatResult struct {
+	CanPass  bool
+	CanFail  bool
+	Pass     map[string]interface{} // env for which the rule is true, when CanPass
+	Fail     map[string]interface{} // env for which the rule is false, when CanFail
+	Complete bool                   // a missing witness means the outcome cannot happen (see Solve)
+}
+
+// Solve decides whether rule can ever pass and ever fail, with no env to start from.
+//
+// The env paths the rule reads are the variables. Each gets a finite set of candidate
+// values from the literals its atoms compare it with: for numbers each literal, a value
+// between consecutive literals and one beyond each end; for strings each literal and
+// one other string; true and false for booleans; and for `"x" in path` lists holding
+// some of the literals tested. Paths tested with contains, startsWith, endsWith, matches
+// or ordered against a string also get the concatenations of two literals, the empty
+// string and each literal followed by "~". Assignments are searched depth first and
+// pruned with Partial as soon as the outcome is decided.
+//
+// When every atom compares one path with literals by equality, membership or numeric
+// order, the candidates cover every case and a missing witness is definitive: Complete
+// is set. Substring, pattern and string order tests, atoms comparing two paths, and
+// atoms computing on paths (`len(roles) > 2`) are only evaluated on the candidates, so
+// a missing witness leaves Complete unset, as does running out of budget. opts apply
+// to evaluating the rule.
+func Solve(rule string, opts ...Option) (SatResult, error) {
+	tree, err := parser.Parse(rule)
+	if err != nil {
+		return SatResult{}, fmt.Errorf("solve: %w", err)
+	}
+	vars, exact := New(nil, opts...).candidates(tree.Node)
+	s := &solver{vars: vars, exact: exact, check: func(env map[string]interface{}, unknown []string) (bool, interface{}) {
+		p, err := Partial(rule, nil, env, unknown, opts...)
+		return err == nil && p.Decided, p.Final
+	}}
+	pass, fail := s.witness(true), s.witness(false)
+	return SatResult{
+		CanPass:  pass.found,
+		CanFail:  fail.found,
+		Pass:     pass.env,
+		Fail:     fail.env,
+		Complete: pass.definitive() && fail.definitive(),
+	}, nil
+}
+
+type solveVar struct {
+	path   string
+	values []interface{}
+}
+
+// solver searches assignments of vars for one where check decides a given value.
+type solver struct {
+	vars  []solveVar
+	exact bool // the candidates cover every case (see candidates)
+	check func(env map[string]interface{}, unknown []string) (decided bool, final interface{})
+	steps int
+}
+
+// found is the outcome of one search.
+type found struct {
+	env      map[string]interface{}
+	found    bool
+	complete bool // the search covered every assignment within the budget
+	exact    bool
+}
+
+// definitive reports whether the search settled the question: a witness was found, or
+// none exists.
+func (f found) definitive() bool { return f.found || f.complete && f.exact }
+
+// witness looks for an assignment check decides as target.
+func (s *solver) witness(target interface{}) found {
+	s.steps = 0
+	assigned := map[string]interface{}{}
+	var dfs func(i int) (bool, bool)
+	dfs = func(i int) (bool, bool) {
+		if s.steps++; s.steps > solveBudget {
+			return false, false
+		}
+		unknown := make([]string, 0, len(s.vars)-i)
+		for _, v := range s.vars[i:] {
+			unknown = append(unknown, v.path)
+		}
+		e, err := access.Expand(assigned)
+		if err != nil {
+			return false, true
+		}
+		if decided, final := s.check(e, unknown); decided {
+			if !reflect.DeepEqual(final, target) {
+				return false, true
+			}
+			for _, v := range s.vars[i:] {
+				assigned[v.path] = v.values[0]
+			}
+			return true, true
+		}
+		if i == len(s.vars) {
+			return false, true // undecided even with every path assigned
+		}
+		complete := true
+		for _, val := range s.vars[i].values {
+			assigned[s.vars[i].path] = val
+			ok, c := dfs(i + 1)
+			if ok {
+				return true, true
+			}
+			complete = complete && c
+			if !c {
+				break
+			}
+		}
+		delete(assigned, s.vars[i].path)
+		return false, complete
+	}
+	ok, complete := dfs(0)
+	out := found{found: ok, complete: complete, exact: s.exact}
+	if ok {
+		out.env, _ = access.Expand(assigned)
+	}
+	return out
+}
+
+// literals collects, per path, what the rule compares it with.
+type literals struct {
+	nums    []float64
+	ints    bool // every number is an integer
+	strs    []string
+	bool    bool
+	nil     bool
+	members []interface{} // literals tested with `in path`
+	links   []string      // paths it is compared with
+	strOps  bool          // tested with a substring, pattern or order operator on strings
+	opaque  bool          // read some other way, e.g. as a function argument
+}
+
+// stringTest reports whether the atom `path op v` tests a string other than by
+// equality, so a finite set of candidates cannot cover every case.
+func stringTest(op string, v interface{}) bool {
+	switch op {
+	case "contains", "startsWith", "endsWith", "matches":
+		return true
+	case "<", "<=", ">", ">=":
+		_, ok := v.(string)
+		return ok
+	}
+	return false
+}
+
+// candidates returns the variables of the rules, sorted by path, with their candidate
+// values. exact is false when some path is read other than by comparing it with
+// literals, or is tested as a string other than by equality, so the candidates may
+// miss cases.
+func (t *Tracer) candidates(roots ...ast.Node) (vars []solveVar, exact bool) {
+	byPath := map[string]*literals{}
+	var order []string
+	for _, root := range roots {
+		for _, p := range access.Reads(root, t.unresolvable(root)) {
+			if _, ok := byPath[p]; !ok {
+				byPath[p] = &literals{ints: true}
+				order = append(order, p)
+			}
+		}
+	}
+	sort.Strings(order)
+	// lit returns what is collected for path; names that are not variables (functions,
+	// let names) collect into a throwaway.
+	lit := func(path string) *literals {
+		if l, ok := byPath[path]; ok {
+			return l
+		}
+		return &literals{}
+	}
+	record := func(l *literals, v interface{}) {
+		switch v := normalize(v).(type) {
+		case float64:
+			l.nums = append(l.nums, v)
+			l.ints = l.ints && v == math.Trunc(v)
+		case string:
+			l.strs = append(l.strs, v)
+		case bool:
+			l.bool = true
+		case nil:
+			l.nil = true
+		}
+	}
+
+	var walk func(n ast.Node, logical bool)
+	walk = func(n ast.Node, logical bool) {
+		n = unparen(n)
+		if path, ok := staticPath(n); ok {
+			if logical {
+				lit(path).bool = true
+			} else {
+				lit(path).opaque = true
+			}
+			return
+		}
+		switch n := n.(type) {
+		case *ast.BinaryNode:
+			switch n.Operator {
+			case "||", "or", "&&", "and":
+				walk(n.Left, true)
+				walk(n.Right, true)
+				return
+			}
+			if patch.IsAtomNode(n) {
+				lp, lok := staticPath(n.Left)
+				rp, rok := staticPath(n.Right)
+				lv, lvok := literalValue(n.Left)
+				rv, rvok := literalValue(n.Right)
+				switch {
+				case lok && rok:
+					lit(lp).links = append(lit(lp).links, rp)
+					lit(rp).links = append(lit(rp).links, lp)
+					lit(lp).opaque, lit(rp).opaque = true, true
+					return
+				case lok && rvok:
+					record(lit(lp), rv)
+					if stringTest(n.Operator, rv) {
+						lit(lp).strOps, lit(lp).opaque = true, true
+					}
+					return
+				case rok && lvok && n.Operator == "in":
+					lit(rp).members = append(lit(rp).members, normalize(lv))
+					return
+				case rok && lvok:
+					record(lit(rp), lv)
+					if stringTest(n.Operator, lv) {
+						lit(rp).strOps, lit(rp).opaque = true, true
+					}
+					return
+				case lok && n.Operator == "in":
+					if arr, ok := n.Right.(*ast.ArrayNode); ok && literalArray(arr) {
+						for _, e := range arr.Nodes {
+							v, _ := literalValue(e)
+							record(lit(lp), v)
+						}
+						return
+					}
+				}
+			}
+		case *ast.UnaryNode:
+			if n.Operator == "not" || n.Operator == "!" {
+				walk(n.Node, true)
+				return
+			}
+		case *ast.ConditionalNode:
+			walk(n.Cond, true)
+			walk(n.Exp1, logical)
+			walk(n.Exp2, logical)
+			return
+		}
+		for _, c := range children(n) {
+			walk(c, false)
+		}
+	}
+	for _, root := range roots {
+		walk(root, true)
+	}
+
+	// Linked paths take each other's literals.
+	for _, p := range order {
+		l := byPath[p]
+		for _, q := range l.links {
+			m := byPath[q]
+			l.nums = append(l.nums, m.nums...)
+			l.ints = l.ints && m.ints
+			l.strs = append(l.strs, m.strs...)
+			l.strOps = l.strOps || m.strOps
+		}
+	}
+
+	exact = true
+	for _, p := range order {
+		if covered(p, order) {
+			continue
+		}
+		exact = exact && !byPath[p].opaque
+		vars = append(vars, solveVar{path: p, values: byPath[p].values()})
+	}
+	return vars, exact
+}
+
+func literalArray(arr *ast.ArrayNode) bool {
+	for _, e := range arr.Nodes {
+		if _, ok := literalValue(e); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// covered reports whether a longer path under p is also a variable; p is then a
+// container, built from its members.
+func covered(p string, paths []string) bool {
+	for _, q := range paths {
+		if q != p && access.Overlaps(p, q) && len(q) > len(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *literals) values() []interface{} {
+	var out []interface{}
+	if len(l.members) > 0 {
+		out = append(out, listCandidates(l.members)...)
+	}
+	if l.bool {
+		out = append(out, true, false)
+	}
+	if len(l.nums) > 0 {
+		out = append(out, numberCandidates(l.nums, l.ints)...)
+	}
+	if len(l.strs) > 0 {
+		strs := dedupStrings(l.strs)
+		out = append(out, strs...)
+		out = append(out, otherString(strs))
+		if l.strOps {
+			out = append(out, stringCandidates(strs)...)
+		}
+	}
+	if len(out) == 0 {
+		out = []interface{}{0, 1}
+	}
+	// A path compared only with nil still needs a non-nil value to fail that test.
+	if l.nil {
+		out = append(out, nil)
+	}
+	return out
+}
+
+// numberCandidates returns each number, one beyond each end and one between each
+// consecutive pair: an integer when the numbers all are and one fits, else a fraction.
+func numberCandidates(nums []float64, ints bool) []interface{} {
+	sort.Float64s(nums)
+	uniq := nums[:0:0]
+	for i, v := range nums {
+		if i == 0 || v != nums[i-1] {
+			uniq = append(uniq, v)
+		}
+	}
+	num := func(v float64) interface{} {
+		if ints && v == math.Trunc(v) {
+			return int(v)
+		}
+		return v
+	}
+	out := []interface{}{num(uniq[0] - 1)}
+	for i, v := range uniq {
+		out = append(out, num(v))
+		if i+1 < len(uniq) {
+			mid := (v + uniq[i+1]) / 2
+			if ints && math.Floor(mid) > v {
+				mid = math.Floor(mid)
+			}
+			out = append(out, num(mid))
+		}
+	}
+	return append(out, num(uniq[len(uniq)-1]+1))
+}
+
+// listCandidates returns lists holding none, each one, all but each one, and all of the
+// members.
+func listCandidates(members []interface{}) []interface{} {
+	var uniq []interface{}
+	seen := map[interface{}]bool{}
+	for _, m := range members {
+		if !seen[m] {
+			seen[m] = true
+			uniq = append(uniq, denormalize(m))
+		}
+	}
+	out := []interface{}{[]interface{}{}}
+	for i := range uniq {
+		out = append(out, []interface{}{uniq[i]})
+	}
+	if len(uniq) > 1 {
+		for i := range uniq {
+			rest := append(append([]interface{}{}, uniq[:i]...), uniq[i+1:]...)
+			if len(rest) > 1 {
+				out = append(out, rest)
+			}
+		}
+		out = append(out, uniq)
+	}
+	return out
+}
+
+// denormalize turns integral floats from normalize back into ints.
+func denormalize(v interface{}) interface{} {
+	if f, ok := v.(float64); ok && f == math.Trunc(f) {
+		return int(f)
+	}
+	return v
+}
+
+func dedupStrings(strs []string) []interface{} {
+	seen := map[string]bool{}
+	var out []interface{}
+	for _, s := range strs {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// stringCandidates returns strings for substring and order tests against strs: the
+// empty string, each literal followed by "~" (after it, before the next literal in
+// most orders), and every concatenation of two different literals.
+func stringCandidates(strs []interface{}) []interface{} {
+	seen := map[interface{}]bool{}
+	for _, s := range strs {
+		seen[s] = true
+	}
+	var out []interface{}
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	add("")
+	for _, s := range strs {
+		add(s.(string) + "~")
+	}
+	for _, a := range strs {
+		for _, b := range strs {
+			if a != b {
+				add(a.(string) + b.(string))
+			}
+		}
+	}
+	return out
+}
+
+// otherString returns a string equal to none of strs.
+func otherString(strs []interface{}) string {
+	other := "other"
+	for {
+		clash := false
+		for _, s := range strs {
+			if s == other {
+				clash = true
+			}
+		}
+		if !clash {
+			return other
+		}
+		other = "_" + other
+	}
+}
+
+// staticPath returns the env path n reads when n is nothing but an identifier with
+// static members.
+func staticPath(n ast.Node) (string, bool) {
+	switch n := unparen(n).(type) {
+	case *ast.IdentifierNode:
+		return n.Value, true
+	case *ast.MemberNode:
+		base, ok := staticPath(n.Node)
+		if !ok || n.Method {
+			return "", false
+		}
+		switch p := n.Property.(type) {
+		case *ast.StringNode:
+			return access.Path(base, p.Value), true
+		case *ast.IntegerNode:
+			return access.Path(base, p.Value), true
+		}
+	}
+	return "", false
+}
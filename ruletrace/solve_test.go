@@ -0,0 +1,51 @@
+package ruletrace
+
+import "testing"
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		canPass  bool
+		canFail  bool
+		complete bool
+	}{
+		{"numbers", `x > 1 && x < 2`, true, true, true},
+		{"integer gap", `x > 1 && x < 1`, false, true, true},
+		{"equality", `name == "a" && name != "a"`, false, true, true},
+		{"membership", `role in ["a", "b"] && role != "a"`, true, true, true},
+		{"contains", `name contains "a" && name contains "b"`, true, true, true},
+		{"startsWith and endsWith", `name startsWith "ab" && name endsWith "cd"`, true, true, true},
+		{"string order", `name > "m" && name < "n"`, true, true, true},
+		{"string order reversed", `"m" < name && "n" > name`, true, true, true},
+		{"matches", `name matches "^a+$" && name contains "b"`, false, true, false},
+		{"startsWith clash", `name startsWith "ab" && name startsWith "b"`, false, true, false},
+		{"endsWith alone", `name endsWith "x"`, true, true, true},
+		{"two paths", `a == b && a != b`, false, true, false},
+		{"nil only", `x == nil`, true, true, true},
+		{"not nil and number", `user.Name != nil && user.Age > 18`, true, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Solve(tt.rule)
+			if err != nil {
+				t.Fatalf("Solve: %v", err)
+			}
+			if res.CanPass != tt.canPass || res.CanFail != tt.canFail || res.Complete != tt.complete {
+				t.Fatalf("CanPass %v CanFail %v Complete %v, want %v %v %v", res.CanPass, res.CanFail, res.Complete, tt.canPass, tt.canFail, tt.complete)
+			}
+			for _, w := range []struct {
+				env  map[string]interface{}
+				want bool
+				ok   bool
+			}{{res.Pass, true, res.CanPass}, {res.Fail, false, res.CanFail}} {
+				if !w.ok {
+					continue
+				}
+				if got := New(w.env, allowUndefined()).Trace(tt.rule, nil).Final; got != w.want {
+					t.Errorf("witness %v gives %v, want %v", w.env, got, w.want)
+				}
+			}
+		})
+	}
+}
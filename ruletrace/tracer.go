@@ -7,9 +7,11 @@ import (
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/ast"
 
+	"github.com/aqilarik/ruletrace/internal/access"
 	"github.com/aqilarik/ruletrace/internal/cond"
 	"github.com/aqilarik/ruletrace/internal/eval"
 	"github.com/aqilarik/ruletrace/internal/format"
+	"github.com/aqilarik/ruletrace/internal/message"
 	"github.com/aqilarik/ruletrace/internal/patch"
 	"github.com/aqilarik/ruletrace/internal/util"
 )
@@ -28,8 +30,30 @@ const (
 	TraceAtomicFailuresOnly
 )
 
+var traceModeNames = [...]string{"TraceNone", "TraceCoarse", "TraceAtomic", "TraceAtomicFailuresOnly"}
+
 func (tm TraceMode) String() string {
-	return [...]string{"TraceNone", "TraceCoarse", "TraceAtomic", "TraceAtomicFailuresOnly"}[tm]
+	return traceModeNames[tm]
+}
+
+// MarshalText spells the mode out, so TraceResult (and every nested Sub trace) encodes
+// it as "TraceAtomic" rather than 2.
+func (tm TraceMode) MarshalText() ([]byte, error) {
+	if int(tm) >= len(traceModeNames) {
+		return nil, fmt.Errorf("ruletrace: unknown trace mode %d", tm)
+	}
+	return []byte(traceModeNames[tm]), nil
+}
+
+// UnmarshalText parses a mode written by MarshalText.
+func (tm *TraceMode) UnmarshalText(text []byte) error {
+	for i, name := range traceModeNames {
+		if name == string(text) {
+			*tm = TraceMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("ruletrace: unknown trace mode %q", text)
 }
 
 // ConditionSpec is metadata attached to an atomic predicate.
@@ -37,24 +61,54 @@ func (tm TraceMode) String() string {
 //
 // ID must be stable and authored by humans/systems. Do NOT derive it from Expr.
 // ReasonTrue / ReasonFalse are both supported so you can explain “why it passed” and “why it failed”.
+// ReasonUnknown opts the atom into three-valued tracing: it is wrapped with Cond3 and a
+// predicate that errors or evaluates to nil is recorded as unknown (see UnknownPolicy).
+// MessageTrue / MessageFalse / MessageUnknown are text/template sources rendered into
+// EvalResult.Message for the matching outcome; see message.Data for what they can reference.
+// Severity, Tags, Description and Priority are opaque to the tracer; they are copied into
+// every chunk carrying the spec's ID, and Priority ranks reasons in TraceResult.PrimaryReason.
 type ConditionSpec = patch.ConditionSpec
 
+// UnknownPolicy decides whether an unknown Cond3 outcome counts as false, counts as true,
+// or propagates the predicate error to the rule.
+type UnknownPolicy = cond.UnknownPolicy
+
+const (
+	UnknownAsFalse   = cond.UnknownAsFalse
+	UnknownAsTrue    = cond.UnknownAsTrue
+	UnknownPropagate = cond.UnknownPropagate
+)
+
 // EvalResult is a single trace item (one evaluated unit shown to UI/logs).
 type EvalResult struct {
-	ID          string      `json:"id,omitempty"`      // semantic stable ID (from ConditionSpec)
-	Fingerprint string      `json:"fingerprint"`       // derived from canonical Expr
-	Expr        string      `json:"expr"`              // canonical expression string of this unit
-	Value       interface{} `json:"value,omitempty"`   // evaluated value (typically bool for atoms)
-	Skipped     bool        `json:"skipped,omitempty"` // short-circuited
-	Error       string      `json:"error,omitempty"`   // evaluation error if any
-	Reason      string      `json:"reason,omitempty"`  // chosen based on true/false for Cond-wrapped atoms
+	ID          string       `json:"id,omitempty"`       // semantic stable ID (from ConditionSpec)
+	Fingerprint string       `json:"fingerprint"`        // derived from canonical Expr
+	Expr        string       `json:"expr"`               // canonical expression string of this unit
+	Value       interface{}  `json:"value,omitempty"`    // evaluated value (typically bool for atoms)
+	Skipped     bool         `json:"skipped,omitempty"`  // short-circuited
+	Unknown     bool         `json:"unknown,omitempty"`  // Cond3 predicate errored or was nil
+	Error       string       `json:"error,omitempty"`    // evaluation error if any
+	Reason      string       `json:"reason,omitempty"`   // chosen based on true/false for Cond-wrapped atoms
+	Message     string       `json:"message,omitempty"`  // rendered from the spec's message template for this outcome
+	Sub         *TraceResult `json:"sub,omitempty"`      // trace of the library condition or Rule(...) this chunk references
+	Decisive    bool         `json:"decisive,omitempty"` // helped decide Final (see PrimaryReason)
+
+	// Copied from the ConditionSpec with the same ID.
+	Severity    string   `json:"severity,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Priority    int      `json:"priority,omitempty"`
 }
 
 type TraceResult struct {
-	Source string       `json:"source"`           // patched canonical source (may include Cond(...))
-	Chunks []EvalResult `json:"chunks,omitempty"` // trace units
-	Final  interface{}  `json:"final,omitempty"`  // final result (authoritative, same execution path)
-	Mode   TraceMode    `json:"mode"`
+	Input    string                 `json:"input,omitempty"`    // original authored expression
+	Source   string                 `json:"source"`             // patched canonical source (may include Cond(...))
+	Chunks   []EvalResult           `json:"chunks,omitempty"`   // trace units
+	Values   []NodeValue            `json:"values,omitempty"`   // sub-expression values (WithNodeValues)
+	Inputs   map[string]interface{} `json:"inputs,omitempty"`   // env paths the rule read, e.g. "user.Group" (WithInputs)
+	Resolved map[string]interface{} `json:"resolved,omitempty"` // paths read through the EnvResolver (WithResolver)
+	Final    interface{}            `json:"final,omitempty"`    // final result (authoritative, same execution path)
+	Mode     TraceMode              `json:"mode"`
 }
 
 // Tracer runs expr-lang expressions with explainability features.
@@ -64,6 +118,19 @@ type Tracer struct {
 	mode         TraceMode
 	shortCircuit bool
 	enableCond   bool
+	unknown      UnknownPolicy
+	messages     *message.Renderer
+	catalog      Catalog
+	locale       string
+	nodeValues   bool
+	inputs       bool
+	undefined    bool
+	library      *Library
+	rules        *Registry
+	memo         *ruleMemo  // Rule(...) traces of the evaluation in progress
+	shared       *eval.Memo // evaluation results shared by a Session
+	resolver     EnvResolver
+	resolved     *resolveCache // resolver results of the evaluation in progress
 }
 
 // New creates a tracer with options.
@@ -73,6 +140,7 @@ func New(env map[string]interface{}, opts ...Option) *Tracer {
 		mode:         TraceAtomic,
 		shortCircuit: true,
 		enableCond:   true,
+		messages:     message.NewRenderer(),
 	}
 	for _, o := range opts {
 		o.apply(t)
@@ -122,23 +190,63 @@ func (t *Tracer) TraceStrict(input string, specs map[string]ConditionSpec) (Trac
 //   - specs: metadata keyed by atom fingerprint used to decide which atoms get wrapped.
 //   - forceFailFast: when true, return an error immediately on compile/eval failure.
 func (t *Tracer) trace(input string, specs map[string]ConditionSpec, forceFailFast bool) (TraceResult, error) {
+	if t.rules != nil && t.memo == nil {
+		// Memoize referenced rules for this evaluation only; nested traces share the memo.
+		tc := *t
+		tc.memo = newRuleMemo()
+		t = &tc
+	}
+	if t.resolver != nil && t.resolved == nil {
+		tc := *t
+		tc.resolved = newResolveCache(t.resolver)
+		t = &tc
+	}
+	rec := cond.NewRecorder(t.unknown)
+	lc := &libraryCalls{subs: map[string]*TraceResult{}, inputs: map[string]interface{}{}}
+	var rp *resolvedPaths
+	if t.resolver != nil {
+		rp = newResolvedPaths()
+	}
 	ec := eval.NewCache()
+	if t.shared != nil {
+		ec = eval.NewSharedCache(t.shared, t.evalKey(), traceEffects{rec: rec, lc: lc, rp: rp}, t.atomPatch)
+	}
 
-	rec := cond.NewRecorder()
 	opts := []expr.Option{expr.Env(t.env)}
+	if t.shared != nil {
+		opts = append(opts, t.atomFuncOption(ec, &opts))
+	}
+	if t.undefined || t.resolver != nil {
+		opts = append(opts, expr.AllowUndefinedVariables())
+	}
 	if t.enableCond {
-		opts = append(opts, expr.Function("Cond", rec.Func()))
+		opts = append(opts,
+			expr.Function("Cond", rec.Func()),
+			expr.Function("Cond3", rec.Func3()),
+			expr.Patch(patch.NilSafe{}),
+		)
+	}
+	if t.rules != nil {
+		opts = append(opts, expr.Function("Rule", t.ruleFunc(lc)))
+	}
+	compileOpts := opts
+	if t.library != nil {
+		funcs, libPatch := t.libraryOptions(lc)
+		opts = append(opts, funcs...)
+		compileOpts = append(opts[:len(opts):len(opts)], libPatch)
 	}
 
 	// 1) Compile original input to get AST
-	tree, err := expr.Compile(input, opts...)
+	tree, err := expr.Compile(input, compileOpts...)
 	if err != nil {
 		res := TraceResult{
+			Input:  input,
 			Source: input,
 			Chunks: []EvalResult{{
 				Fingerprint: Fingerprint(input),
 				Expr:        input,
 				Error:       err.Error(),
+				Decisive:    true,
 			}},
 			Final: nil,
 			Mode:  t.mode,
@@ -150,6 +258,22 @@ func (t *Tracer) trace(input string, specs map[string]ConditionSpec, forceFailFa
 	}
 	root := tree.Node()
 
+	// Names missing from the env are resolved lazily, by every evaluation from here on.
+	var (
+		resolved   map[string]interface{}
+		unresolved func(string) bool
+	)
+	if t.resolver != nil {
+		resolved, unresolved = rp.seen, t.unresolvable(root)
+		rr := access.NewResolvingRecorder(t.env, t.resolved.resolver(rp), unresolved)
+		opts = append(opts, rr.Options()...)
+	}
+
+	// Sub-expression values are captured on the authored AST, before patching rewrites it.
+	var values []NodeValue
+	if t.nodeValues {
+		values = t.captureValues(root, format.New(), ec, opts...)
+	}
 	// 2) Patch atoms into Cond(...) if enabled and specs present
 	fmter := format.New()
 	if t.enableCond && len(specs) > 0 {
@@ -159,13 +283,51 @@ func (t *Tracer) trace(input string, specs map[string]ConditionSpec, forceFailFa
 	// 3) Trace chunks on patched AST
 	chunks := t.evalChunks(root, fmter, ec, opts...)
 
-	// 4) Authoritative final evaluation uses patched canonical source
+	// 4) Authoritative final evaluation uses patched canonical source.
+	// Env reads are recorded on this run only, so Inputs is exactly what the decision read.
 	patchedSource := fmter.Format(root)
-	final, _ := eval.EvalString(patchedSource, t.env, ec, opts...)
+	var (
+		final  interface{}
+		inputs map[string]interface{}
+	)
+	if t.inputs {
+		ar := access.NewRecorder(t.env)
+		if t.resolver != nil {
+			ar = access.NewResolvingRecorder(t.env, t.resolved.resolver(rp), unresolved)
+		}
+		lc.inputs = map[string]interface{}{}
+		final, _ = eval.EvalString(patchedSource, t.env, eval.NewCache(), append(opts, ar.Options()...)...)
+		inputs = ar.Seen()
+		for path, v := range lc.inputs {
+			inputs[path] = v
+		}
+	} else {
+		final, _ = eval.EvalString(patchedSource, t.env, ec, opts...)
+	}
 
-	// 5) Enrich Cond chunks with semantic ID + reason from recorder.
+	// 5) Enrich Cond chunks with semantic ID + reason from recorder, spec metadata by ID,
+	// and the sub-trace of library condition references.
+	byID := specsByID(specs)
+	if t.library != nil {
+		for _, c := range t.library.snapshot() {
+			if _, ok := byID[c.Spec.ID]; !ok {
+				byID[c.Spec.ID] = c.Spec
+			}
+		}
+	}
 	for i := range chunks {
-		if !strings.HasPrefix(chunks[i].Expr, "Cond(") {
+		if chunks[i].Skipped {
+			if s, ok := byID[chunks[i].ID]; ok {
+				applySpec(&chunks[i], s)
+			}
+			continue
+		}
+		if name, ok := util.ExtractRuleName(chunks[i].Expr); ok && t.memo != nil {
+			chunks[i].ID = name
+			chunks[i].Sub = t.memo.traces[name]
+			continue
+		}
+		if !strings.HasPrefix(chunks[i].Expr, "Cond(") && !strings.HasPrefix(chunks[i].Expr, "Cond3(") {
 			continue
 		}
 		id := util.ExtractFirstStringArg(chunks[i].Expr)
@@ -178,13 +340,25 @@ func (t *Tracer) trace(input string, specs map[string]ConditionSpec, forceFailFa
 		}
 		chunks[i].ID = rr.ID
 		chunks[i].Reason = rr.Reason
+		chunks[i].Unknown = rr.Unknown
+		chunks[i].Sub = t.librarySub(lc, rr.ID, chunks[i].Expr, fmter, ec, opts...)
+		if s, ok := byID[rr.ID]; ok {
+			applySpec(&chunks[i], s)
+		}
 	}
 
+	// 6) Render reason messages from the catalog or spec templates.
+	t.renderMessages(chunks, byID, fmter, ec, opts...)
+
 	return TraceResult{
-		Source: patchedSource,
-		Chunks: chunks,
-		Final:  final,
-		Mode:   t.mode,
+		Input:    input,
+		Source:   patchedSource,
+		Chunks:   chunks,
+		Values:   values,
+		Inputs:   inputs,
+		Resolved: resolved,
+		Final:    final,
+		Mode:     t.mode,
 	}, nil
 }
 
@@ -192,56 +366,126 @@ func (t *Tracer) evalChunks(node ast.Node, fmter *format.Formatter, ec *eval.Cac
 	if t.mode == TraceNone {
 		return nil
 	}
+	chunks, _ := t.traceNode(node, fmter, ec, opts...)
+	return chunks
+}
 
+// traceNode returns the chunks of node and its value. Short-circuit operators are traced
+// structurally, marking skipped subtrees; their value is combined from their operands'
+// values, so deciding what to skip never evaluates a subtree twice. Chunks stay Decisive
+// only while their operand decides each operator above it: `a || b` keeps a's when a is
+// true, b's when b is true and both when both are false.
+func (t *Tracer) traceNode(node ast.Node, fmter *format.Formatter, ec *eval.Cache, opts ...expr.Option) ([]EvalResult, EvalResult) {
 	if ch, ok := node.(*ast.ChainNode); ok {
-		return t.evalChunks(ch.Node, fmter, ec, opts...)
+		return t.traceNode(ch.Node, fmter, ec, opts...)
 	}
 
-	// Short-circuit ops: structurally trace left/right and mark skipped subtrees.
 	if bn, ok := node.(*ast.BinaryNode); ok && patch.IsShortCircuitOp(bn.Operator) {
+		left, lv := t.traceNode(bn.Left, fmter, ec, opts...)
+		var decided bool
+		switch bn.Operator {
+		case "||", "or":
+			decided = isTrue(lv)
+		case "&&", "and":
+			decided = isFalse(lv)
+		case "??":
+			decided = isNotNil(lv)
+		}
+		if decided && t.shortCircuit {
+			return append(left, t.markSkipped(bn.Right, fmter)...), lv
+		}
+		right, rv := t.traceNode(bn.Right, fmter, ec, opts...)
+		if decided {
+			return append(left, undecisive(right)...), lv
+		}
+		v := combine(bn.Operator, lv, rv)
 		switch bn.Operator {
 		case "||", "or":
-			left := t.evalChunks(bn.Left, fmter, ec, opts...)
-			if t.shortCircuit && lastTrue(left) {
-				return append(left, t.markSkipped(bn.Right, fmter)...)
+			if isFalse(lv) && isTrue(rv) {
+				undecisive(left)
 			}
-			return append(left, t.evalChunks(bn.Right, fmter, ec, opts...)...)
 		case "&&", "and":
-			left := t.evalChunks(bn.Left, fmter, ec, opts...)
-			if t.shortCircuit && lastFalse(left) {
-				return append(left, t.markSkipped(bn.Right, fmter)...)
+			if isTrue(lv) && isFalse(rv) {
+				undecisive(left)
 			}
-			return append(left, t.evalChunks(bn.Right, fmter, ec, opts...)...)
 		case "??":
-			left := t.evalChunks(bn.Left, fmter, ec, opts...)
-			if t.shortCircuit && lastNotNil(left) {
-				return append(left, t.markSkipped(bn.Right, fmter)...)
+			if lv.Error == "" {
+				undecisive(left)
 			}
-			return append(left, t.evalChunks(bn.Right, fmter, ec, opts...)...)
 		}
+		return append(left, right...), v
 	}
 
 	if t.mode == TraceCoarse {
-		return []EvalResult{t.evalNode(node, fmter, ec, opts...)}
+		r := t.evalNode(node, fmter, ec, opts...)
+		r.Decisive = true
+		return []EvalResult{r}, r
 	}
 
 	atoms := patch.CollectAtoms(node)
 	if len(atoms) == 0 {
-		return []EvalResult{t.evalNode(node, fmter, ec, opts...)}
+		r := t.evalNode(node, fmter, ec, opts...)
+		r.Decisive = true
+		return []EvalResult{r}, r
 	}
 
-	out := make([]EvalResult, 0, len(atoms))
+	results := make([]EvalResult, 0, len(atoms))
 	for _, a := range atoms {
 		r := t.evalNode(a, fmter, ec, opts...)
-		if t.mode == TraceAtomicFailuresOnly {
-			if r.Skipped || r.Error != "" || r.Value == false || r.Value == nil {
-				out = append(out, r)
+		r.Decisive = true
+		results = append(results, r)
+	}
+	value := t.leafValue(node, atoms, results, fmter, ec, opts...)
+	if t.mode != TraceAtomicFailuresOnly {
+		return results, value
+	}
+	out := results[:0]
+	for _, r := range results {
+		if r.Skipped || r.Error != "" || r.Value == false || r.Value == nil {
+			out = append(out, r)
+		}
+	}
+	return out, value
+}
+
+// leafValue returns the value of a node traced as atoms: the atom's own result when the
+// node is that atom or its negation, else the node evaluated as a unit.
+func (t *Tracer) leafValue(node ast.Node, atoms []ast.Node, results []EvalResult, fmter *format.Formatter, ec *eval.Cache, opts ...expr.Option) EvalResult {
+	if len(atoms) == 1 {
+		if atoms[0] == node {
+			return results[0]
+		}
+		if un, ok := node.(*ast.UnaryNode); ok && (un.Operator == "not" || un.Operator == "!") && unchain(un.Node) == atoms[0] {
+			if b, ok := results[0].Value.(bool); ok && results[0].Error == "" {
+				return EvalResult{Value: !b}
 			}
-			continue
 		}
-		out = append(out, r)
 	}
-	return out
+	return t.evalNode(node, fmter, ec, opts...)
+}
+
+// combine is what expr makes of `left op right` when left did not decide it: right for
+// `||` and `&&` if it is a bool, right for `??` if left is nil, else an error.
+func combine(op string, left, right EvalResult) EvalResult {
+	switch op {
+	case "||", "or", "&&", "and":
+		_, lok := left.Value.(bool)
+		if _, rok := right.Value.(bool); lok && rok && left.Error == "" && right.Error == "" {
+			return right
+		}
+	case "??":
+		if left.Error == "" && left.Value == nil {
+			return right
+		}
+	}
+	return EvalResult{Error: "invalid operands for " + op}
+}
+
+func unchain(n ast.Node) ast.Node {
+	if ch, ok := n.(*ast.ChainNode); ok {
+		return ch.Node
+	}
+	return n
 }
 
 func (t *Tracer) evalNode(node ast.Node, fmter *format.Formatter, ec *eval.Cache, opts ...expr.Option) EvalResult {
@@ -257,8 +501,15 @@ func (t *Tracer) evalNode(node ast.Node, fmter *format.Formatter, ec *eval.Cache
 
 func (t *Tracer) markSkipped(node ast.Node, fmter *format.Formatter) []EvalResult {
 	exprStr := fmter.Format(node)
+	id := ""
+	if cid, ok := patch.CondID(node); ok {
+		id = cid
+	} else if name, ok := patch.RuleCallName(node); ok {
+		id = name
+	}
 	return []EvalResult{
 		{
+			ID:          id,
 			Fingerprint: Fingerprint(exprStr),
 			Expr:        exprStr,
 			Value:       nil,
@@ -267,14 +518,34 @@ func (t *Tracer) markSkipped(node ast.Node, fmter *format.Formatter) []EvalResul
 	}
 }
 
-func lastTrue(r []EvalResult) bool {
-	return len(r) > 0 && r[len(r)-1].Error == "" && r[len(r)-1].Value == true
+// undecisive clears Decisive on chunks whose operand did not decide its operator.
+func undecisive(chunks []EvalResult) []EvalResult {
+	for i := range chunks {
+		chunks[i].Decisive = false
+	}
+	return chunks
 }
-func lastFalse(r []EvalResult) bool {
-	return len(r) > 0 && r[len(r)-1].Error == "" && r[len(r)-1].Value == false
+
+func isTrue(r EvalResult) bool   { return r.Error == "" && r.Value == true }
+func isFalse(r EvalResult) bool  { return r.Error == "" && r.Value == false }
+func isNotNil(r EvalResult) bool { return r.Error == "" && r.Value != nil }
+
+// applySpec copies the spec's presentation metadata into a chunk.
+func applySpec(c *EvalResult, s ConditionSpec) {
+	c.Severity = s.Severity
+	c.Tags = s.Tags
+	c.Description = s.Description
+	c.Priority = s.Priority
 }
-func lastNotNil(r []EvalResult) bool {
-	return len(r) > 0 && r[len(r)-1].Error == "" && r[len(r)-1].Value != nil
+
+func specsByID(specs map[string]ConditionSpec) map[string]ConditionSpec {
+	out := make(map[string]ConditionSpec, len(specs))
+	for _, s := range specs {
+		if s.ID != "" {
+			out[s.ID] = s
+		}
+	}
+	return out
 }
 
 // ValidateSpecs is a lightweight guard for obvious mistakes.
@@ -0,0 +1,87 @@
+package ruletrace
+
+import (
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/aqilarik/ruletrace/internal/format"
+)
+
+// TraceNode is one node of the evaluation tree rebuilt from a trace: boolean structure
+// (`||`, `&&`, `??`, `not`, ternary) as inner nodes, trace units as leaves.
+type TraceNode struct {
+	Op       string       // "||", "&&", "??", "not" or "?:" for inner nodes, "" for leaves
+	Expr     string       // canonical expression of the subtree
+	Chunk    *EvalResult  // trace unit covering exactly this subtree, if any
+	Children []*TraceNode // operands in evaluation order; ternary is cond, then, else
+}
+
+// Tree rebuilds the evaluation tree from Source and attaches chunks by expression.
+// Chains of the same operator are flattened into one node. Leaves without a chunk are
+// sub-expressions the trace did not report on (e.g. filtered by the mode).
+func (r TraceResult) Tree() (*TraceNode, error) {
+	tree, err := parser.Parse(r.Source)
+	if err != nil {
+		return nil, err
+	}
+	byExpr := make(map[string]*EvalResult, len(r.Chunks))
+	for i := range r.Chunks {
+		if _, ok := byExpr[r.Chunks[i].Expr]; !ok {
+			byExpr[r.Chunks[i].Expr] = &r.Chunks[i]
+		}
+	}
+	b := treeBuilder{fmter: format.New(), byExpr: byExpr}
+	return b.node(tree.Node), nil
+}
+
+type treeBuilder struct {
+	fmter  *format.Formatter
+	byExpr map[string]*EvalResult
+}
+
+func (b treeBuilder) node(n ast.Node) *TraceNode {
+	if ch, ok := n.(*ast.ChainNode); ok {
+		n = ch.Node
+	}
+	out := &TraceNode{Expr: b.fmter.Format(n)}
+	if c, ok := b.byExpr[out.Expr]; ok {
+		out.Chunk = c
+		return out
+	}
+
+	switch x := n.(type) {
+	case *ast.BinaryNode:
+		switch x.Operator {
+		case "||", "or":
+			out.Op = "||"
+			out.Children = b.chain(x, "||", "or")
+		case "&&", "and":
+			out.Op = "&&"
+			out.Children = b.chain(x, "&&", "and")
+		case "??":
+			out.Op = "??"
+			out.Children = b.chain(x, "??", "??")
+		}
+	case *ast.UnaryNode:
+		if x.Operator == "not" || x.Operator == "!" {
+			out.Op = "not"
+			out.Children = []*TraceNode{b.node(x.Node)}
+		}
+	case *ast.ConditionalNode:
+		out.Op = "?:"
+		out.Children = []*TraceNode{b.node(x.Cond), b.node(x.Exp1), b.node(x.Exp2)}
+	}
+	return out
+}
+
+func (b treeBuilder) chain(n ast.Node, ops ...string) []*TraceNode {
+	if ch, ok := n.(*ast.ChainNode); ok {
+		n = ch.Node
+	}
+	if bn, ok := n.(*ast.BinaryNode); ok && (bn.Operator == ops[0] || bn.Operator == ops[1]) {
+		if _, covered := b.byExpr[b.fmter.Format(bn)]; !covered {
+			return append(b.chain(bn.Left, ops...), b.chain(bn.Right, ops...)...)
+		}
+	}
+	return []*TraceNode{b.node(n)}
+}
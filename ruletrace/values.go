@@ -0,0 +1,116 @@
+package ruletrace
+
+import (
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+
+	"github.com/aqilarik/ruletrace/internal/eval"
+	"github.com/aqilarik/ruletrace/internal/format"
+)
+
+// NodeValue is the value of one sub-expression of the traced input (see WithNodeValues).
+type NodeValue struct {
+	Expr  string      `json:"expr"`            // canonical expression string of the node
+	Pos   int         `json:"pos"`             // rune offset in TraceResult.Input the value is anchored at
+	Value interface{} `json:"value,omitempty"` // evaluated value
+	Error string      `json:"error,omitempty"` // evaluation error if any
+}
+
+// captureValues evaluates every non-literal node of the unpatched AST, following the same
+// short-circuit and branch selection as the rule, so skipped subtrees get no values.
+// Bodies of predicates and let-bound expressions are not entered: they cannot be evaluated
+// outside their scope. Values are anchored where expr places the node: operators for unary
+// and binary nodes, the property for member access, the name for calls and identifiers.
+func (t *Tracer) captureValues(node ast.Node, fmter *format.Formatter, ec *eval.Cache, opts ...expr.Option) []NodeValue {
+	var out []NodeValue
+
+	var walk func(n ast.Node) (interface{}, string)
+	record := func(n ast.Node) (interface{}, string) {
+		exprStr := fmter.Format(n)
+		val, errStr := eval.EvalString(exprStr, t.env, ec, opts...)
+		out = append(out, NodeValue{Expr: exprStr, Pos: n.Location().From, Value: val, Error: errStr})
+		return val, errStr
+	}
+
+	walk = func(n ast.Node) (interface{}, string) {
+		switch x := n.(type) {
+		case nil, *ast.NilNode, *ast.IntegerNode, *ast.FloatNode, *ast.BoolNode, *ast.StringNode,
+			*ast.ConstantNode, *ast.PointerNode, *ast.PredicateNode:
+			return nil, ""
+
+		case *ast.ChainNode:
+			return walk(x.Node)
+
+		case *ast.UnaryNode:
+			walk(x.Node)
+
+		case *ast.BinaryNode:
+			left, errStr := walk(x.Left)
+			if t.shortCircuit && errStr == "" {
+				switch x.Operator {
+				case "||", "or":
+					if left == true {
+						return record(x)
+					}
+				case "&&", "and":
+					if left == false {
+						return record(x)
+					}
+				case "??":
+					if left != nil {
+						return record(x)
+					}
+				}
+			}
+			walk(x.Right)
+
+		case *ast.ConditionalNode:
+			c, errStr := walk(x.Cond)
+			switch {
+			case errStr != "":
+			case c == true:
+				return walk(x.Exp1)
+			case c == false:
+				return walk(x.Exp2)
+			}
+			return nil, errStr
+
+		case *ast.MemberNode:
+			walk(x.Node)
+			if _, ok := x.Property.(*ast.StringNode); !ok {
+				walk(x.Property)
+			}
+
+		case *ast.SliceNode:
+			walk(x.Node)
+			walk(x.From)
+			walk(x.To)
+
+		case *ast.CallNode:
+			if m, ok := x.Callee.(*ast.MemberNode); ok {
+				walk(m.Node)
+			}
+			for _, a := range x.Arguments {
+				walk(a)
+			}
+
+		case *ast.BuiltinNode:
+			for _, a := range x.Arguments {
+				walk(a)
+			}
+
+		case *ast.ArrayNode:
+			for _, el := range x.Nodes {
+				walk(el)
+			}
+			return nil, ""
+
+		case *ast.MapNode, *ast.PairNode, *ast.SequenceNode, *ast.VariableDeclaratorNode:
+			return nil, ""
+		}
+		return record(n)
+	}
+
+	walk(node)
+	return out
+}
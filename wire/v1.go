@@ -0,0 +1,95 @@
+package wire
+
+import "fmt"
+
+// traceV1 is the wire form of VersionV1. It is only read: its chunks have no sub traces,
+// it has no inputs or resolved paths, and its values no uint type.
+type traceV1 struct {
+	Schema string        `json:"schema"`
+	Input  string        `json:"input,omitempty"`
+	Source string        `json:"source"`
+	Mode   string        `json:"mode"`
+	Final  valueV1       `json:"final"`
+	Chunks []chunkV1     `json:"chunks"`
+	Values []nodeValueV1 `json:"values,omitempty"`
+}
+
+type chunkV1 struct {
+	ID          string   `json:"id,omitempty"`
+	Fingerprint string   `json:"fingerprint"`
+	Expr        string   `json:"expr"`
+	Value       valueV1  `json:"value"`
+	Skipped     bool     `json:"skipped,omitempty"`
+	Unknown     bool     `json:"unknown,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+	Message     string   `json:"message,omitempty"`
+	Severity    string   `json:"severity,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Priority    int      `json:"priority,omitempty"`
+}
+
+type nodeValueV1 struct {
+	Expr  string  `json:"expr"`
+	Pos   int     `json:"pos"`
+	Value valueV1 `json:"value"`
+	Error string  `json:"error,omitempty"`
+}
+
+// valueV1 is a Value that rejects the types v1 does not have.
+type valueV1 struct{ Value }
+
+func (v *valueV1) UnmarshalJSON(data []byte) error {
+	if err := v.Value.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	if hasUint(v.Value) {
+		return fmt.Errorf("uint value: not a %s type", VersionV1)
+	}
+	return nil
+}
+
+func hasUint(v Value) bool {
+	switch x := v.Value.(type) {
+	case []Value:
+		for _, e := range x {
+			if hasUint(e) {
+				return true
+			}
+		}
+	case map[string]Value:
+		for _, e := range x {
+			if hasUint(e) {
+				return true
+			}
+		}
+	}
+	return v.Type == TypeUint
+}
+
+// trace converts a v1 trace to the current wire form.
+func (t traceV1) trace() Trace {
+	out := Trace{Schema: t.Schema, Input: t.Input, Source: t.Source, Mode: t.Mode, Final: t.Final.Value, Chunks: make([]Chunk, 0, len(t.Chunks))}
+	for _, c := range t.Chunks {
+		out.Chunks = append(out.Chunks, Chunk{
+			ID:          c.ID,
+			Fingerprint: c.Fingerprint,
+			Expr:        c.Expr,
+			Value:       c.Value.Value,
+			Skipped:     c.Skipped,
+			Unknown:     c.Unknown,
+			Error:       c.Error,
+			Reason:      c.Reason,
+			Message:     c.Message,
+			Severity:    c.Severity,
+			Tags:        c.Tags,
+			Description: c.Description,
+			Priority:    c.Priority,
+		})
+	}
+	for _, v := range t.Values {
+		out.Values = append(out.Values, NodeValue{Expr: v.Expr, Pos: v.Pos, Value: v.Value.Value, Error: v.Error})
+	}
+	return out
+}
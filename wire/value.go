@@ -0,0 +1,248 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Type tags of wire values.
+const (
+	TypeNil    = "nil"
+	TypeBool   = "bool"
+	TypeInt    = "int"
+	TypeUint   = "uint" // v2
+	TypeFloat  = "float"
+	TypeString = "string"
+	TypeList   = "list"
+	TypeMap    = "map"
+)
+
+// Value is a type-tagged value: {"type": "int", "value": 3}.
+//
+// Signed and unsigned integers of every width are "int" and decode to int, except
+// unsigned values above math.MaxInt64, which are "uint" and decode to uint64; floats are
+// "float" and decode to float64 (NaN and ±Inf are written as the strings "NaN", "+Inf",
+// "-Inf"). Slices and arrays are "list" ([]interface{}); maps and structs are "map"
+// (map[string]interface{}, keys formatted with %v, exported struct fields by name).
+// Other types are written as their %v string.
+type Value struct {
+	Type  string
+	Value interface{} // bool, int, uint64, float64, string, []Value, map[string]Value or nil
+}
+
+// NewValue tags v.
+func NewValue(v interface{}) Value {
+	if v == nil {
+		return Value{Type: TypeNil}
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return Value{Type: TypeBool, Value: rv.Bool()}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Value{Type: TypeInt, Value: int(rv.Int())}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u > math.MaxInt64 {
+			return Value{Type: TypeUint, Value: u}
+		}
+		return Value{Type: TypeInt, Value: int(rv.Uint())}
+	case reflect.Float32, reflect.Float64:
+		return Value{Type: TypeFloat, Value: rv.Float()}
+	case reflect.String:
+		return Value{Type: TypeString, Value: rv.String()}
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return Value{Type: TypeNil}
+		}
+		list := make([]Value, rv.Len())
+		for i := range list {
+			list[i] = NewValue(rv.Index(i).Interface())
+		}
+		return Value{Type: TypeList, Value: list}
+	case reflect.Map:
+		if rv.IsNil() {
+			return Value{Type: TypeNil}
+		}
+		m := make(map[string]Value, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[fmt.Sprintf("%v", iter.Key().Interface())] = NewValue(iter.Value().Interface())
+		}
+		return Value{Type: TypeMap, Value: m}
+	case reflect.Struct:
+		m := map[string]Value{}
+		for i := 0; i < rv.NumField(); i++ {
+			if f := rv.Type().Field(i); f.IsExported() {
+				m[f.Name] = NewValue(rv.Field(i).Interface())
+			}
+		}
+		return Value{Type: TypeMap, Value: m}
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return Value{Type: TypeNil}
+		}
+		return NewValue(rv.Elem().Interface())
+	default:
+		return Value{Type: TypeString, Value: fmt.Sprintf("%v", v)}
+	}
+}
+
+// Interface returns the Go value: nil, bool, int, uint64, float64, string,
+// []interface{} or map[string]interface{}.
+func (v Value) Interface() interface{} {
+	switch x := v.Value.(type) {
+	case []Value:
+		out := make([]interface{}, len(x))
+		for i := range x {
+			out[i] = x[i].Interface()
+		}
+		return out
+	case map[string]Value:
+		out := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			out[k] = e.Interface()
+		}
+		return out
+	default:
+		return x
+	}
+}
+
+type rawValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func (v Value) MarshalJSON() ([]byte, error) {
+	var payload interface{} = v.Value
+	switch v.Type {
+	case TypeNil:
+		return []byte(`{"type":"nil"}`), nil
+	case TypeFloat:
+		f, _ := v.Value.(float64)
+		switch {
+		case math.IsNaN(f):
+			payload = "NaN"
+		case math.IsInf(f, 1):
+			payload = "+Inf"
+		case math.IsInf(f, -1):
+			payload = "-Inf"
+		}
+	case TypeMap:
+		// sorted keys keep the output deterministic
+		m, _ := v.Value.(map[string]Value)
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			kb, _ := json.Marshal(k)
+			vb, err := m[k].MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(kb)
+			buf.WriteByte(':')
+			buf.Write(vb)
+		}
+		buf.WriteByte('}')
+		payload = json.RawMessage(buf.Bytes())
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rawValue{Type: v.Type, Value: raw})
+}
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var raw rawValue
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	v.Type = raw.Type
+	v.Value = nil
+	switch raw.Type {
+	case TypeNil:
+		return nil
+	case TypeBool:
+		var b bool
+		if err := json.Unmarshal(raw.Value, &b); err != nil {
+			return fmt.Errorf("bool value: %w", err)
+		}
+		v.Value = b
+	case TypeInt:
+		var n json.Number
+		if err := json.Unmarshal(raw.Value, &n); err != nil {
+			return fmt.Errorf("int value: %w", err)
+		}
+		i, err := strconv.ParseInt(n.String(), 10, 64)
+		if err != nil {
+			return fmt.Errorf("int value: %w", err)
+		}
+		v.Value = int(i)
+	case TypeUint:
+		var n json.Number
+		if err := json.Unmarshal(raw.Value, &n); err != nil {
+			return fmt.Errorf("uint value: %w", err)
+		}
+		u, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			return fmt.Errorf("uint value: %w", err)
+		}
+		v.Value = u
+	case TypeFloat:
+		var s string
+		if json.Unmarshal(raw.Value, &s) == nil {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("float value: %w", err)
+			}
+			v.Value = f
+			return nil
+		}
+		var f float64
+		if err := json.Unmarshal(raw.Value, &f); err != nil {
+			return fmt.Errorf("float value: %w", err)
+		}
+		v.Value = f
+	case TypeString:
+		var s string
+		if err := json.Unmarshal(raw.Value, &s); err != nil {
+			return fmt.Errorf("string value: %w", err)
+		}
+		v.Value = s
+	case TypeList:
+		var list []Value
+		if err := json.Unmarshal(raw.Value, &list); err != nil {
+			return fmt.Errorf("list value: %w", err)
+		}
+		if list == nil {
+			list = []Value{}
+		}
+		v.Value = list
+	case TypeMap:
+		var m map[string]Value
+		if err := json.Unmarshal(raw.Value, &m); err != nil {
+			return fmt.Errorf("map value: %w", err)
+		}
+		if m == nil {
+			m = map[string]Value{}
+		}
+		v.Value = m
+	default:
+		return fmt.Errorf("unknown value type %q", raw.Type)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+// Package wire is the versioned, stable serialization of ruletrace traces.
+//
+// Unlike encoding TraceResult directly, the wire format names the schema version,
+// spells out the trace mode, and tags every value with its type so it decodes back
+// into the same Go types (see Value). Marshal writes Version; Unmarshal reads every
+// version listed below, each with its own types, so a v1 trace cannot carry what v2
+// added. A published schema is never changed: new fields or value
+// types get a new version.
+//
+//   - ruletrace.trace/v1 (SchemaV1): the trace, chunks and node values.
+//   - ruletrace.trace/v2 (SchemaV2): adds inputs, resolved, nested sub traces, decisive
+//     chunks and the uint value type.
+package wire
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aqilarik/ruletrace/ruletrace"
+)
+
+// Version identifies the current wire schema; it is written to every trace.
+const Version = VersionV2
+
+// Supported wire versions.
+const (
+	VersionV1 = "ruletrace.trace/v1"
+	VersionV2 = "ruletrace.trace/v2"
+)
+
+// SchemaV1 is the JSON Schema (draft 2020-12) of VersionV1.
+//
+//go:embed schema/v1.json
+var SchemaV1 []byte
+
+// SchemaV2 is the JSON Schema (draft 2020-12) of VersionV2.
+//
+//go:embed schema/v2.json
+var SchemaV2 []byte
+
+// Trace is the wire form of ruletrace.TraceResult.
+type Trace struct {
+	Schema   string           `json:"schema"`
+	Input    string           `json:"input,omitempty"`
+	Source   string           `json:"source"`
+	Mode     string           `json:"mode"`
+	Final    Value            `json:"final"`
+	Chunks   []Chunk          `json:"chunks"`
+	Values   []NodeValue      `json:"values,omitempty"`
+	Inputs   map[string]Value `json:"inputs,omitempty"`
+	Resolved map[string]Value `json:"resolved,omitempty"`
+}
+
+// Chunk is the wire form of ruletrace.EvalResult.
+type Chunk struct {
+	ID          string   `json:"id,omitempty"`
+	Fingerprint string   `json:"fingerprint"`
+	Expr        string   `json:"expr"`
+	Value       Value    `json:"value"`
+	Skipped     bool     `json:"skipped,omitempty"`
+	Unknown     bool     `json:"unknown,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+	Message     string   `json:"message,omitempty"`
+	Severity    string   `json:"severity,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Priority    int      `json:"priority,omitempty"`
+	Sub         *Trace   `json:"sub,omitempty"`
+	Decisive    bool     `json:"decisive,omitempty"` // v2
+}
+
+// NodeValue is the wire form of ruletrace.NodeValue.
+type NodeValue struct {
+	Expr  string `json:"expr"`
+	Pos   int    `json:"pos"`
+	Value Value  `json:"value"`
+	Error string `json:"error,omitempty"`
+}
+
+var modes = []ruletrace.TraceMode{
+	ruletrace.TraceNone,
+	ruletrace.TraceCoarse,
+	ruletrace.TraceAtomic,
+	ruletrace.TraceAtomicFailuresOnly,
+}
+
+// FromResult converts a trace to its wire form.
+func FromResult(res ruletrace.TraceResult) Trace {
+	out := Trace{
+		Schema: Version,
+		Input:  res.Input,
+		Source: res.Source,
+		Mode:   res.Mode.String(),
+		Final:  NewValue(res.Final),
+		Chunks: make([]Chunk, 0, len(res.Chunks)),
+	}
+	for _, c := range res.Chunks {
+		var sub *Trace
+		if c.Sub != nil {
+			st := FromResult(*c.Sub)
+			sub = &st
+		}
+		out.Chunks = append(out.Chunks, Chunk{
+			ID:          c.ID,
+			Fingerprint: c.Fingerprint,
+			Expr:        c.Expr,
+			Value:       NewValue(c.Value),
+			Skipped:     c.Skipped,
+			Unknown:     c.Unknown,
+			Error:       c.Error,
+			Reason:      c.Reason,
+			Message:     c.Message,
+			Severity:    c.Severity,
+			Tags:        c.Tags,
+			Description: c.Description,
+			Priority:    c.Priority,
+			Sub:         sub,
+			Decisive:    c.Decisive,
+		})
+	}
+	for _, v := range res.Values {
+		out.Values = append(out.Values, NodeValue{Expr: v.Expr, Pos: v.Pos, Value: NewValue(v.Value), Error: v.Error})
+	}
+	out.Inputs = newValues(res.Inputs)
+	out.Resolved = newValues(res.Resolved)
+	return out
+}
+
+// Result converts a wire trace back to a TraceResult.
+func (t Trace) Result() (ruletrace.TraceResult, error) {
+	res := ruletrace.TraceResult{
+		Input:  t.Input,
+		Source: t.Source,
+		Final:  t.Final.Interface(),
+	}
+	mode, ok := parseMode(t.Mode)
+	if !ok {
+		return res, fmt.Errorf("wire: unknown mode %q", t.Mode)
+	}
+	res.Mode = mode
+	for _, c := range t.Chunks {
+		var sub *ruletrace.TraceResult
+		if c.Sub != nil {
+			sr, err := c.Sub.Result()
+			if err != nil {
+				return res, err
+			}
+			sub = &sr
+		}
+		res.Chunks = append(res.Chunks, ruletrace.EvalResult{
+			ID:          c.ID,
+			Fingerprint: c.Fingerprint,
+			Expr:        c.Expr,
+			Value:       c.Value.Interface(),
+			Skipped:     c.Skipped,
+			Unknown:     c.Unknown,
+			Error:       c.Error,
+			Reason:      c.Reason,
+			Message:     c.Message,
+			Severity:    c.Severity,
+			Tags:        c.Tags,
+			Description: c.Description,
+			Priority:    c.Priority,
+			Sub:         sub,
+			Decisive:    c.Decisive,
+		})
+	}
+	for _, v := range t.Values {
+		res.Values = append(res.Values, ruletrace.NodeValue{Expr: v.Expr, Pos: v.Pos, Value: v.Value.Interface(), Error: v.Error})
+	}
+	res.Inputs = interfaces(t.Inputs)
+	res.Resolved = interfaces(t.Resolved)
+	return res, nil
+}
+
+// newValues converts a path-keyed value map; nil stays nil.
+func newValues(m map[string]interface{}) map[string]Value {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]Value, len(m))
+	for k, v := range m {
+		out[k] = NewValue(v)
+	}
+	return out
+}
+
+func interfaces(m map[string]Value) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v.Interface()
+	}
+	return out
+}
+
+// Marshal encodes a trace in the current wire version.
+func Marshal(res ruletrace.TraceResult) ([]byte, error) {
+	return json.Marshal(FromResult(res))
+}
+
+// Unmarshal decodes a wire trace of any supported version.
+func Unmarshal(data []byte) (ruletrace.TraceResult, error) {
+	var head struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return ruletrace.TraceResult{}, fmt.Errorf("wire: %w", err)
+	}
+	switch head.Schema {
+	case VersionV1:
+		// v1 has its own types, so nothing v2 added is read from a v1 trace
+		var t traceV1
+		if err := json.Unmarshal(data, &t); err != nil {
+			return ruletrace.TraceResult{}, fmt.Errorf("wire: %w", err)
+		}
+		return t.trace().Result()
+	case VersionV2:
+		var t Trace
+		if err := json.Unmarshal(data, &t); err != nil {
+			return ruletrace.TraceResult{}, fmt.Errorf("wire: %w", err)
+		}
+		return t.Result()
+	case "":
+		return ruletrace.TraceResult{}, fmt.Errorf("wire: missing schema version")
+	default:
+		return ruletrace.TraceResult{}, fmt.Errorf("wire: unsupported schema version %q", head.Schema)
+	}
+}
+
+func parseMode(s string) (ruletrace.TraceMode, bool) {
+	for _, m := range modes {
+		if m.String() == s {
+			return m, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,218 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aqilarik/ruletrace/ruletrace"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden is the trace testdata/v2.json encodes; testdata/v1.json is the same trace
+// without the fields v2 added.
+func golden() ruletrace.TraceResult {
+	sub := ruletrace.TraceResult{
+		Input:  `user.Id == owner`,
+		Source: `Cond("c_owner_id", "OWNER", "NOT_OWNER", user.Id == owner)`,
+		Final:  true,
+		Mode:   ruletrace.TraceAtomic,
+		Chunks: []ruletrace.EvalResult{{ID: "c_owner_id", Fingerprint: "fp-owner-id", Expr: `Cond("c_owner_id", "OWNER", "NOT_OWNER", user.Id == owner)`, Value: true, Reason: "OWNER", Decisive: true}},
+	}
+	return ruletrace.TraceResult{
+		Input:  `user.Age >= 18 && is_owner(user, post.Owner)`,
+		Source: `Cond("c_age", "ADULT", "MINOR", user.Age >= 18) && Cond("c_owner", "OWNER", "NOT_OWNER", is_owner(user, post.Owner))`,
+		Final:  true,
+		Mode:   ruletrace.TraceAtomic,
+		Chunks: []ruletrace.EvalResult{
+			{ID: "c_age", Fingerprint: "fp-age", Expr: `Cond("c_age", "ADULT", "MINOR", user.Age >= 18)`, Value: true, Reason: "ADULT", Severity: "high", Tags: []string{"age"}, Priority: 2, Decisive: true},
+			{ID: "c_owner", Fingerprint: "fp-owner", Expr: `Cond("c_owner", "OWNER", "NOT_OWNER", is_owner(user, post.Owner))`, Value: true, Reason: "OWNER", Sub: &sub, Decisive: true},
+		},
+		Values: []ruletrace.NodeValue{{Expr: "user.Age", Pos: 0, Value: 20}, {Expr: "post.Owner", Pos: 31, Value: uint64(math.MaxUint64)}},
+		Inputs: map[string]interface{}{
+			"user.Age":   20,
+			"user.Id":    7,
+			"post.Owner": uint64(math.MaxUint64),
+			"user.Score": math.Inf(1),
+		},
+		Resolved: map[string]interface{}{"user.Tags": []interface{}{"a", nil, 1.5}},
+	}
+}
+
+func TestGoldenV2(t *testing.T) {
+	path := filepath.Join("testdata", "v2.json")
+	got, err := Marshal(golden())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, got, "", "  "); err != nil {
+		t.Fatalf("Indent: %v", err)
+	}
+	indented.WriteByte('\n')
+	if *update {
+		if err := os.WriteFile(path, indented.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(indented.Bytes(), want) {
+		t.Errorf("Marshal differs from %s (run with -update if intended):\n%s", path, indented.Bytes())
+	}
+
+	res, err := Unmarshal(want)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(res, golden()) {
+		t.Errorf("Unmarshal(%s) = %+v\nwant %+v", path, res, golden())
+	}
+}
+
+func TestGoldenV1(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := golden()
+	want.Chunks[0].Decisive, want.Chunks[1].Decisive = false, false
+	want.Chunks[1].Sub = nil
+	want.Values[1].Value = 7
+	want.Inputs, want.Resolved = nil, nil
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Unmarshal(v1) = %+v\nwant %+v", res, want)
+	}
+}
+
+// TestGoldenV1Uint checks that a v1 trace holding a v2 value type is rejected.
+func TestGoldenV1Uint(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "v1_uint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Unmarshal(data); err == nil || !strings.Contains(err.Error(), "uint") {
+		t.Fatalf("Unmarshal(v1 with uint) error = %v, want a uint error", err)
+	}
+}
+
+// TestV1IgnoresV2Fields checks that fields v2 added are not read from a v1 trace.
+func TestV1IgnoresV2Fields(t *testing.T) {
+	v2, err := Marshal(golden())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	v1 := bytes.Replace(v2, []byte(VersionV2), []byte(VersionV1), 1)
+	v1 = bytes.Replace(v1, []byte(`{"type":"uint","value":18446744073709551615}`), []byte(`{"type":"int","value":7}`), -1)
+	res, err := Unmarshal(v1)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if res.Inputs != nil || res.Resolved != nil || res.Chunks[1].Sub != nil {
+		t.Errorf("v1 trace read v2 fields: inputs %v, resolved %v, sub %+v", res.Inputs, res.Resolved, res.Chunks[1].Sub)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name, data string
+	}{
+		{"missing schema", `{"source":"a"}`},
+		{"unknown schema", `{"schema":"ruletrace.trace/v9"}`},
+		{"unknown mode", `{"schema":"ruletrace.trace/v2","mode":"TraceLoud","final":{"type":"nil"}}`},
+		{"unknown value type", `{"schema":"ruletrace.trace/v2","mode":"TraceAtomic","final":{"type":"decimal","value":1}}`},
+		{"int out of range", `{"schema":"ruletrace.trace/v2","mode":"TraceAtomic","final":{"type":"int","value":18446744073709551615}}`},
+		{"uint in v1", `{"schema":"ruletrace.trace/v1","mode":"TraceAtomic","final":{"type":"uint","value":18446744073709551615}}`},
+		{"nested uint in v1", `{"schema":"ruletrace.trace/v1","mode":"TraceAtomic","final":{"type":"list","value":[{"type":"uint","value":18446744073709551615}]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Unmarshal([]byte(tt.data)); err == nil {
+				t.Error("Unmarshal succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	type point struct {
+		X, Y int
+		note string
+	}
+	tests := []struct {
+		name string
+		in   interface{}
+		json string
+		out  interface{}
+	}{
+		{"nil", nil, `{"type":"nil"}`, nil},
+		{"int64 min", int64(math.MinInt64), `{"type":"int","value":-9223372036854775808}`, math.MinInt64},
+		{"uint8", uint8(200), `{"type":"int","value":200}`, 200},
+		{"uint64 in int range", uint64(math.MaxInt64), `{"type":"int","value":9223372036854775807}`, math.MaxInt64},
+		{"uint64 above int range", uint64(math.MaxUint64), `{"type":"uint","value":18446744073709551615}`, uint64(math.MaxUint64)},
+		{"float32", float32(1.5), `{"type":"float","value":1.5}`, 1.5},
+		{"NaN", math.NaN(), `{"type":"float","value":"NaN"}`, nil},
+		{"-Inf", math.Inf(-1), `{"type":"float","value":"-Inf"}`, math.Inf(-1)},
+		{"string", "a\"b", `{"type":"string","value":"a\"b"}`, "a\"b"},
+		{"nil slice", []int(nil), `{"type":"nil"}`, nil},
+		{"array", [2]string{"a", "b"}, `{"type":"list","value":[{"type":"string","value":"a"},{"type":"string","value":"b"}]}`, []interface{}{"a", "b"}},
+		{"int-keyed map", map[int]bool{2: true, 1: false}, `{"type":"map","value":{"1":{"type":"bool","value":false},"2":{"type":"bool","value":true}}}`, map[string]interface{}{"1": false, "2": true}},
+		{"struct pointer", &point{X: 1, Y: 2, note: "x"}, `{"type":"map","value":{"X":{"type":"int","value":1},"Y":{"type":"int","value":2}}}`, map[string]interface{}{"X": 1, "Y": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(NewValue(tt.in))
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(b) != tt.json {
+				t.Errorf("Marshal = %s, want %s", b, tt.json)
+			}
+			var v Value
+			if err := json.Unmarshal(b, &v); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			got := v.Interface()
+			if tt.name == "NaN" {
+				if f, ok := got.(float64); !ok || !math.IsNaN(f) {
+					t.Errorf("Interface = %v, want NaN", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.out) {
+				t.Errorf("Interface = %#v, want %#v", got, tt.out)
+			}
+		})
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	for version, schema := range map[string][]byte{VersionV1: SchemaV1, VersionV2: SchemaV2} {
+		var s struct {
+			Properties struct {
+				Schema struct {
+					Const string `json:"const"`
+				} `json:"schema"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(schema, &s); err != nil {
+			t.Fatalf("%s schema: %v", version, err)
+		}
+		if s.Properties.Schema.Const != version {
+			t.Errorf("%s schema pins %q", version, s.Properties.Schema.Const)
+		}
+	}
+}